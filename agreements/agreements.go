@@ -0,0 +1,289 @@
+package agreements
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+)
+
+// The event codes of the agreements contract, the first indexed parameter of its events. Each code
+// has its own event.
+type EventCode uint64
+
+const (
+	Created EventCode = iota
+	Created_detail
+	Create_fraud_alert
+	Consumer_terminated
+	Producer_terminated
+	Terminate_fraud_alert
+	Admin_deleted
+)
+
+var event_names = []string{"CreatedAgreement", "CreatedDetail", "CreateFraudAlert", "ConsumerTerminated", "ProducerTerminated", "TerminateFraudAlert", "AdminDeleted"}
+
+// Return the name of the event emitted with the code.
+func (c EventCode) String() string {
+	if uint64(c) < uint64(len(event_names)) {
+		return event_names[c]
+	}
+	return fmt.Sprintf("EventCode(%d)", uint64(c))
+}
+
+// The part an account plays in an agreement. The consumer creates the agreement with the
+// signature of the producer, its counter party.
+type Role int
+
+const (
+	Consumer Role = iota
+	Producer
+)
+
+func (r Role) String() string {
+	if r == Consumer {
+		return "consumer"
+	}
+	return "producer"
+}
+
+// The ways an agreements operation can fail. Use errors.Is to test an error returned by this
+// package against one of these, e.g. errors.Is(err, ErrAgreementExists).
+var (
+	ErrBadSignature    = errors.New("producer signature does not match")
+	ErrNoCounterParty  = errors.New("no counter party")
+	ErrAgreementExists = errors.New("agreement already exists")
+	ErrNoAgreement     = errors.New("no such agreement")
+	ErrNotAdmin        = errors.New("not the contract owner")
+)
+
+// An error from an agreements operation. Kind is one of the Err sentinels above, or nil when the
+// contract or the node failed in some other way.
+type AgreementError struct {
+	msg  string
+	Kind error
+}
+
+func (e *AgreementError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+// Allow errors.Is to match the error against its kind.
+func (e *AgreementError) Is(target error) bool {
+	return e != nil && e.Kind != nil && e.Kind == target
+}
+
+// A typed client for the agreements contract, contracts/agreements.sol, which records agreements
+// between a consumer and a producer. The account the contract sends transactions from is the
+// caller; the contract works out its role in an agreement from the counter party given. The
+// contract is used as given, so it must already be loaded and have its address set.
+type Agreements struct {
+	contract *contract_api.SolidityContract
+}
+
+func AgreementsFactory(contract *contract_api.SolidityContract) *Agreements {
+	return &Agreements{contract: contract}
+}
+
+func (self *Agreements) Get_contract() *contract_api.SolidityContract {
+	return self.contract
+}
+
+// An agreement to create, by the consumer. ContractHash is the hash of the terms the producer
+// signed, and ProducerSig the 65 byte signature of the producer over it.
+type CreateRequest struct {
+	AgreementID  []byte
+	ContractHash []byte
+	ProducerSig  []byte
+	Producer     string
+}
+
+// An agreement to end, by either party. Reason is recorded in the termination event.
+type TerminateRequest struct {
+	AgreementID  []byte
+	CounterParty string
+	Reason       uint64
+}
+
+// An agreement as the caller sees it.
+type Agreement struct {
+	AgreementID  []byte
+	ContractHash []byte
+	ProducerSig  []byte
+	Role         Role
+	CounterParty string
+}
+
+// Create the agreement, as the consumer. A zero producer is refused first, as the contract does,
+// and the producer's signature is then checked locally, the way the contract checks it. The creation
+// is then tried with eth_call, so that a refusal is reported as an error instead of a transaction
+// that does nothing, or one that raises a fraud alert because the signature doesn't match.
+func (self *Agreements) Create(ctx context.Context, req *CreateRequest) error {
+	if strings.Trim(strings.TrimPrefix(req.Producer, "0x"), "0") == "" {
+		return &AgreementError{msg: fmt.Sprintf("Unable to create agreement %x, the producer is the zero address.", req.AgreementID), Kind: ErrNoCounterParty}
+	} else if signer, err := contract_api.Recover_signer(req.ContractHash, req.ProducerSig); err != nil {
+		return &AgreementError{msg: fmt.Sprintf("Unable to create agreement %x, error: %v", req.AgreementID, err), Kind: ErrBadSignature}
+	} else if !strings.EqualFold(signer, req.Producer) {
+		return &AgreementError{msg: fmt.Sprintf("Unable to create agreement %x, the contract hash is signed by %v instead of producer %v.", req.AgreementID, signer, req.Producer), Kind: ErrBadSignature}
+	}
+	params := []interface{}{req.AgreementID, req.ContractHash, req.ProducerSig, req.Producer}
+	res, err := self.contract.Simulate_method(ctx, "create_agreement", params)
+	if err == nil {
+		err = return_code_error("create_agreement", res, req.AgreementID, map[uint64]error{1: ErrBadSignature, 2: ErrNoCounterParty, 3: ErrAgreementExists})
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "create_agreement", params)
+	}
+	return err
+}
+
+// End the agreement, as either party. The termination is tried with eth_call first, so that an
+// agreement the caller isn't part of is reported as ErrNoAgreement instead of a fraud alert.
+func (self *Agreements) Terminate(ctx context.Context, req *TerminateRequest) error {
+	params := []interface{}{req.CounterParty, req.AgreementID, req.Reason}
+	res, err := self.contract.Simulate_method(ctx, "terminate_agreement", params)
+	if err == nil {
+		err = return_code_error("terminate_agreement", res, req.AgreementID, map[uint64]error{1: ErrNoAgreement})
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "terminate_agreement", params)
+	}
+	return err
+}
+
+// Delete any agreement, as the owner of the contract.
+func (self *Agreements) Admin_delete(ctx context.Context, consumer string, producer string, agreement_id []byte, reason uint64) error {
+	params := []interface{}{consumer, producer, agreement_id, reason}
+	res, err := self.contract.Simulate_method(ctx, "admin_delete_agreement", params)
+	if err == nil {
+		err = return_code_error("admin_delete_agreement", res, agreement_id, map[uint64]error{1: ErrNoAgreement, 2: ErrNotAdmin})
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "admin_delete_agreement", params)
+	}
+	return err
+}
+
+// Return the role of the caller in its agreement with the counter party, or ErrNoAgreement when
+// there is none.
+func (self *Agreements) Role_of(ctx context.Context, counter_party string, agreement_id []byte) (Role, error) {
+	calls := []*contract_api.MethodCall{
+		{Method: "callerIsConsumer", Params: []interface{}{counter_party, agreement_id}},
+		{Method: "callerIsProducer", Params: []interface{}{counter_party, agreement_id}},
+	}
+	err := self.contract.Invoke_constant_methods_ctx(ctx, calls)
+	for ix := 0; err == nil && ix < len(calls); ix++ {
+		err = calls[ix].Error
+	}
+	if err != nil {
+		return Consumer, err
+	} else if calls[0].Result == true {
+		return Consumer, nil
+	} else if calls[1].Result == true {
+		return Producer, nil
+	}
+	return Consumer, &AgreementError{msg: fmt.Sprintf("There is no agreement %x with %v.", agreement_id, counter_party), Kind: ErrNoAgreement}
+}
+
+// Return the agreement the caller has with the counter party, or ErrNoAgreement when there is none.
+func (self *Agreements) Get(ctx context.Context, counter_party string, agreement_id []byte) (*Agreement, error) {
+	role, err := self.Role_of(ctx, counter_party, agreement_id)
+	if err != nil {
+		return nil, err
+	}
+	calls := []*contract_api.MethodCall{
+		{Method: "get_contract_hash", Params: []interface{}{counter_party, agreement_id}},
+		{Method: "get_producer_signature", Params: []interface{}{counter_party, agreement_id}},
+	}
+	err = self.contract.Invoke_constant_methods_ctx(ctx, calls)
+	for ix := 0; err == nil && ix < len(calls); ix++ {
+		err = calls[ix].Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash, ok1 := calls[0].Result.(string)
+	sig, ok2 := calls[1].Result.([]byte)
+	if !ok1 || !ok2 {
+		return nil, &AgreementError{msg: fmt.Sprintf("Unable to read agreement %x with %v, got hash %v and signature %v.", agreement_id, counter_party, calls[0].Result, calls[1].Result)}
+	}
+	return &Agreement{AgreementID: agreement_id, ContractHash: []byte(hash), ProducerSig: sig, Role: role, CounterParty: counter_party}, nil
+}
+
+// Map the return code of a method to an error.
+func return_code_error(method string, res interface{}, agreement_id []byte, kinds map[uint64]error) error {
+	code, ok := res.(uint64)
+	if !ok {
+		return &AgreementError{msg: fmt.Sprintf("%v returned %v for agreement %x, expected a return code.", method, res, agreement_id)}
+	} else if code == 0 {
+		return nil
+	} else if kind, ok := kinds[code]; ok {
+		return &AgreementError{msg: fmt.Sprintf("%v refused agreement %x with code %v: %v.", method, agreement_id, code, kind), Kind: kind}
+	}
+	return &AgreementError{msg: fmt.Sprintf("%v returned unknown code %v for agreement %x.", method, code, agreement_id)}
+}
+
+// Something that happened to an agreement, decoded from one of the events of the contract.
+// ContractHash and ProducerSig are only set for Created_detail and Create_fraud_alert, Reason only
+// for the terminations and Admin_deleted. Removed is set when a chain reorganisation undid it.
+type AgreementEvent struct {
+	Code         EventCode
+	Consumer     string
+	Producer     string
+	AgreementID  []byte
+	ContractHash []byte
+	ProducerSig  []byte
+	Reason       uint64
+	Removed      bool
+	Log          contract_api.Log
+}
+
+// All the events are anonymous and share their indexed parameters, so a filter on one of them
+// matches all of them; the event code tells them apart.
+var agreement_filter = contract_api.EventFilter{Event: "CreatedAgreement"}
+
+// Decode an event delivered for the agreements contract, such as by Subscribe or Query_logs.
+func (self *Agreements) Decode_event(ev *contract_api.Event) (*AgreementEvent, error) {
+	if len(ev.Log.Topics) != 4 {
+		return nil, &AgreementError{msg: fmt.Sprintf("Unable to decode agreements event from log %v in block %v, it has %v topics.", ev.Log.LogIndex, ev.Log.BlockNumber, len(ev.Log.Topics))}
+	}
+	code := EventCode(contract_api.Hex_to_uint64(ev.Log.Topics[0]))
+	if uint64(code) >= uint64(len(event_names)) {
+		return nil, &AgreementError{msg: fmt.Sprintf("Unknown agreements event code %v in block %v.", uint64(code), ev.Log.BlockNumber)}
+	}
+
+	full, err := self.contract.Decode_event_as(code.String(), &ev.Log)
+	if err != nil {
+		return nil, err
+	} else if full.Data == nil {
+		return nil, &AgreementError{msg: fmt.Sprintf("Unable to decode the data of %v event from log %v in block %v.", code, ev.Log.LogIndex, ev.Log.BlockNumber)}
+	}
+	id, _ := hex.DecodeString(strings.TrimPrefix(ev.Log.Topics[3], "0x"))
+	res := &AgreementEvent{Code: code, AgreementID: id, Removed: ev.Removed, Log: ev.Log}
+	res.Consumer, _ = full.Indexed["_consumer"].(string)
+	res.Producer, _ = full.Indexed["_producer"].(string)
+	if hash, ok := full.Data["_contractHash"].(string); ok {
+		res.ContractHash = []byte(hash)
+	}
+	res.ProducerSig, _ = full.Data["_producerSig"].([]byte)
+	res.Reason, _ = full.Data["_reason_code"].(uint64)
+	return res, nil
+}
+
+// Call the handler with each event of the contract, until the context is cancelled or the
+// subscription is ended. Logs that can't be decoded are skipped.
+func (self *Agreements) Subscribe(ctx context.Context, handler func(ev *AgreementEvent)) (*contract_api.Subscription, error) {
+	filter := agreement_filter
+	return self.contract.Subscribe(ctx, &filter, func(ev *contract_api.Event) {
+		if aev, err := self.Decode_event(ev); err == nil {
+			handler(aev)
+		}
+	})
+}
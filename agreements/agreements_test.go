@@ -0,0 +1,147 @@
+package agreements
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+	"github.com/open-horizon/go-solidity/contract_api/contracttest"
+)
+
+const (
+	testAgreements = "0xb37e8570f16682474894d435b207bb9a67dec3d9"
+	testConsumer   = "0x1111111111111111111111111111111111111111"
+	testProducer   = "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf" // The account with private key 2
+)
+
+var (
+	testID   = bytes.Repeat([]byte{0xab}, 32)
+	testHash = bytes.Repeat([]byte{0xcd}, 32)
+	// The signature of testHash by testProducer.
+	testSig, _ = hex.DecodeString("0789dee922a809e21365f515c10b61bdc44f18eb5cf7e4f71d492ac8be15757619dc56df24b0ac176c31337fe2f4212f5f18daa8d3ceb4ab49fceeafb61ec13f1b")
+)
+
+// The results of the node for the calls that aren't about the contract itself.
+var testResults = map[string]interface{}{"eth_blockNumber": "0x10"}
+
+// The ABI encoding of a byte array, after its offset.
+func encoded_bytes(b []byte) string {
+	h := hex.EncodeToString(b)
+	return contracttest.Word("41") + h + strings.Repeat("0", (64-len(h)%64)%64)
+}
+
+func testAgreementsClient(t *testing.T, node *contracttest.Node) (*Agreements, *httptest.Server) {
+	server := httptest.NewServer(node)
+	sc := contract_api.SolidityContractFactory("agreements")
+	if err := sc.Load_artifact_file("../contracts/agreements.json"); err != nil {
+		t.Fatalf("Unable to load the agreements contract, error: %v\n", err)
+	}
+	sc.Set_rpcurl(server.URL)
+	sc.Set_from(testConsumer)
+	sc.Set_skip_eventlistener()
+	sc.Set_contract_address(testAgreements)
+	return AgreementsFactory(sc), server
+}
+
+func TestAgreements(t *testing.T) {
+	node := &contracttest.Node{Results: testResults, Outputs: map[string]string{
+		"create_agreement(bytes32,bytes32,bytes,address)":         "0x" + contracttest.Word("1"),
+		"terminate_agreement(address,bytes32,uint256)":            "0x" + contracttest.Word("1"),
+		"admin_delete_agreement(address,address,bytes32,uint256)": "0x" + contracttest.Word("2"),
+		"callerIsConsumer(address,bytes32)":                       "0x" + contracttest.Word("0"),
+		"callerIsProducer(address,bytes32)":                       "0x" + contracttest.Word("1"),
+		"get_contract_hash(address,bytes32)":                      "0x" + hex.EncodeToString(testHash),
+		"get_producer_signature(address,bytes32)":                 "0x" + contracttest.Word("20") + encoded_bytes(testSig),
+	}}
+	ag, server := testAgreementsClient(t, node)
+	defer server.Close()
+	ctx := context.Background()
+
+	// A zero producer is refused before its signature is checked, as the contract does.
+	req := &CreateRequest{AgreementID: testID, ContractHash: testHash, ProducerSig: testSig, Producer: "0x0000000000000000000000000000000000000000"}
+	if err := ag.Create(ctx, req); !errors.Is(err, ErrNoCounterParty) {
+		t.Errorf("Create returned %v, expected %v\n", err, ErrNoCounterParty)
+	}
+
+	// A signature that isn't the producer's is refused locally.
+	req = &CreateRequest{AgreementID: testID, ContractHash: testHash, ProducerSig: bytes.Repeat([]byte{0xef}, 65), Producer: testProducer}
+	if err := ag.Create(ctx, req); !errors.Is(err, ErrBadSignature) || !strings.Contains(err.Error(), "agreement") {
+		t.Errorf("Create returned %v, expected %v\n", err, ErrBadSignature)
+	}
+	req.ProducerSig, req.Producer = testSig, testConsumer
+	if err := ag.Create(ctx, req); !errors.Is(err, ErrBadSignature) || !strings.Contains(err.Error(), testProducer) {
+		t.Errorf("Create returned %v, expected %v by %v\n", err, ErrBadSignature, testProducer)
+	}
+
+	// Refusals are found by eth_call and never sent as transactions.
+	req.Producer = testProducer
+	if err := ag.Create(ctx, req); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Create returned %v, expected %v\n", err, ErrBadSignature)
+	}
+	node.Outputs["create_agreement(bytes32,bytes32,bytes,address)"] = "0x" + contracttest.Word("3")
+	if err := ag.Create(ctx, req); !errors.Is(err, ErrAgreementExists) {
+		t.Errorf("Create returned %v, expected %v\n", err, ErrAgreementExists)
+	}
+	if err := ag.Terminate(ctx, &TerminateRequest{AgreementID: testID, CounterParty: testProducer, Reason: 1}); !errors.Is(err, ErrNoAgreement) {
+		t.Errorf("Terminate returned %v, expected %v\n", err, ErrNoAgreement)
+	}
+	if err := ag.Admin_delete(ctx, testConsumer, testProducer, testID, 1); !errors.Is(err, ErrNotAdmin) {
+		t.Errorf("Admin_delete returned %v, expected %v\n", err, ErrNotAdmin)
+	}
+	if n := node.Calls("eth_sendTransaction"); n != 0 {
+		t.Errorf("%v refused operations were sent as transactions\n", n)
+	}
+
+	if a, err := ag.Get(ctx, testProducer, testID); err != nil {
+		t.Errorf("Get returned error: %v\n", err)
+	} else if a.Role != Producer || !bytes.Equal(a.ContractHash, testHash) || !bytes.Equal(a.ProducerSig, testSig) {
+		t.Errorf("Get returned %v with hash %x and signature %x, expected the producer's agreement\n", a.Role, a.ContractHash, a.ProducerSig)
+	}
+	node.Outputs["callerIsProducer(address,bytes32)"] = "0x" + contracttest.Word("0")
+	if _, err := ag.Role_of(ctx, testProducer, testID); !errors.Is(err, ErrNoAgreement) {
+		t.Errorf("Role_of returned %v, expected %v\n", err, ErrNoAgreement)
+	}
+}
+
+func TestAgreementEvents(t *testing.T) {
+	topics := func(code string) []string {
+		return []string{"0x" + contracttest.Word(code), "0x" + contracttest.Word(testConsumer[2:]), "0x" + contracttest.Word(testProducer[2:]), "0x" + hex.EncodeToString(testID)}
+	}
+	node := &contracttest.Node{Results: testResults, Logs: []interface{}{
+		map[string]interface{}{"blockNumber": "0x5", "logIndex": "0x0", "data": "0x", "topics": topics("0")},
+		map[string]interface{}{"blockNumber": "0x5", "logIndex": "0x1", "data": "0x" + hex.EncodeToString(testHash) + contracttest.Word("40") + encoded_bytes(testSig), "topics": topics("1")},
+		map[string]interface{}{"blockNumber": "0x6", "logIndex": "0x0", "data": "0x" + contracttest.Word("7"), "topics": topics("3")},
+	}}
+	ag, server := testAgreementsClient(t, node)
+	defer server.Close()
+
+	events := make([]*AgreementEvent, 0, 3)
+	err := ag.Get_contract().Query_logs(context.Background(), &contract_api.LogQuery{Filter: agreement_filter, FromBlock: 1, ToBlock: 0x10}, func(ev *contract_api.Event) {
+		if aev, err := ag.Decode_event(ev); err != nil {
+			t.Errorf("Decode_event returned error: %v\n", err)
+		} else {
+			events = append(events, aev)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Query_logs returned error: %v\n", err)
+	} else if len(events) != 3 {
+		t.Fatalf("Decoded %v events, expected 3\n", len(events))
+	}
+	for ix, code := range []EventCode{Created, Created_detail, Consumer_terminated} {
+		if ev := events[ix]; ev.Code != code || ev.Consumer != testConsumer || ev.Producer != testProducer || !bytes.Equal(ev.AgreementID, testID) {
+			t.Errorf("Event %v decoded as %v between %v and %v, expected %v\n", ix, ev.Code, ev.Consumer, ev.Producer, code)
+		}
+	}
+	if ev := events[1]; !bytes.Equal(ev.ContractHash, testHash) || !bytes.Equal(ev.ProducerSig, testSig) {
+		t.Errorf("%v decoded with hash %x and signature %x\n", ev.Code, ev.ContractHash, ev.ProducerSig)
+	}
+	if ev := events[2]; ev.Reason != 7 {
+		t.Errorf("%v decoded with reason %v, expected 7\n", ev.Code, ev.Reason)
+	}
+}
@@ -2,9 +2,9 @@ package main
 
 import (
     "bytes"
+    "context"
     "fmt"
     "encoding/hex"
-    "encoding/json"
     "log"
     "github.com/open-horizon/go-solidity/contract_api"
     "math/big"
@@ -75,36 +75,21 @@ func main() {
     fmt.Println("Hash and sign a simple string.")
     smarter_contract := "{long string of contract terms}"
     hash_string := "0x" + hex.EncodeToString([]byte(smarter_contract))
-    var rpcResp *rpcResponse = new(rpcResponse)
-    sig_hash, sig, out := "", "", ""
-
-    if out, err = ag.Call_rpc_api("web3_sha3", hash_string); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("RPC hash of terms and conditions failed, error: %v.", rpcResp.Error.Message)
-                os.Exit(1)
-            } else {
-                sig_hash = rpcResp.Result.(string)
-                log.Printf("Hash of terms and conditions is: %v\n", sig_hash)
-            }
-        }
+    ec := ag.Get_client()
+    sig_hash, sig := "", ""
+
+    if sig_hash, err = ec.Sha3(context.Background(), hash_string); err != nil {
+        log.Printf("RPC hash of terms and conditions failed, error: %v.", err)
+        os.Exit(1)
     }
+    log.Printf("Hash of terms and conditions is: %v\n", sig_hash)
 
-    // if out, err = ag.Call_rpc_api("personal_sign", contract_api.MultiValueParams{sig_hash, agreements_owner, "bob"}); err == nil {
-    if out, err = ag.Call_rpc_api("eth_sign", contract_api.MultiValueParams{agreements_owner, sig_hash}); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("RPC sign of terms and conditions hash failed, error: %v.", rpcResp.Error.Message)
-                os.Exit(1)
-            } else {
-                sig = rpcResp.Result.(string)
-                log.Printf("Signature of terms and conditions hash is: %v\n", sig)
-            }
-        } else {
-            log.Printf("Unmarshal error: %v.", err)
-            os.Exit(1)
-        }
+    // if sig, err = ec.Personal_sign(context.Background(), sig_hash, agreements_owner, "bob"); err != nil {
+    if sig, err = ec.Sign(context.Background(), agreements_owner, sig_hash); err != nil {
+        log.Printf("RPC sign of terms and conditions hash failed, error: %v.", err)
+        os.Exit(1)
     }
+    log.Printf("Signature of terms and conditions hash is: %v\n", sig)
 
     if len(sig[2:]) != 130 {
         log.Printf("Signature has wrong length: %v.", len(sig[2:]))
@@ -251,40 +236,20 @@ func main() {
     // The events should match the sequence of operations that occurred above.
 
     log.Printf("Dumping blockchain event data for contract %v.\n",ag.Get_contract_address())
-    out, err = "", error(nil)
-    rpcResp = new(rpcResponse)
+    err = error(nil)
     result := ""
+    var events []contract_api.Log
 
-    params := make(map[string]string)
-    params["address"] = ag.Get_contract_address()
-    params["fromBlock"] = "0x1"
-
-    if out, err = ag.Call_rpc_api("eth_newFilter", params); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("eth_newFilter returned an error: %v.\n", rpcResp.Error.Message)
-            } else {
-                result = rpcResp.Result.(string)
-                // log.Printf("Event id: %v.\n",result)
-            }
-        }
-    }
+    query := &contract_api.FilterQuery{Address: ag.Get_contract_address(), FromBlock: "0x1"}
 
-    rpcFilterResp := new(rpcGetFilterChangesResponse)
-    if out, err = ag.Call_rpc_api("eth_getFilterLogs", result); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcFilterResp); err == nil {
-            if rpcFilterResp.Error.Message != "" {
-                log.Printf("eth_getFilterChanges returned an error: %v.\n", rpcFilterResp.Error.Message)
-            }
-        }
-    } else {
+    if result, err = ec.New_filter(context.Background(), query); err != nil {
+        log.Printf("eth_newFilter returned an error: %v.\n", err)
+    } else if events, err = ec.Get_filter_logs(context.Background(), result); err != nil {
         log.Printf("Error calling getFilterLogs: %v.\n",err)
     }
 
-    if len(rpcFilterResp.Result) > 0 {
-        for ix, ev := range rpcFilterResp.Result {
-            format_ag_event(ix, ev, tr);
-        }
+    for ix, ev := range events {
+        format_ag_event(ix, ev, tr);
     }
 
 
@@ -448,7 +413,7 @@ func terminate_agreement(ag *contract_api.SolidityContract, agID []byte, counter
 
 // For an event that is found in the blockchain, format it and write it out to the
 // testcase log.
-func format_ag_event(ix int, ev rpcFilterChanges, tr *TestResults) {
+func format_ag_event(ix int, ev contract_api.Log, tr *TestResults) {
     // These event strings correspond to event codes from the agreements contract
     ag_cr8        := "0x0000000000000000000000000000000000000000000000000000000000000000"
     ag_cr8_detail := "0x0000000000000000000000000000000000000000000000000000000000000001"
@@ -509,38 +474,6 @@ func (t *TestResults) String() string {
     return fmt.Sprintf("Success: %v, Fraud: %v, Delete: %v", t.Successful, t.Fraud, t.Delete)
 }
 
-// Mappings of structures used in the ethereum RPC API
-type rpcResponse struct {
-    Id      string      `json:"id"`
-    Version string      `json:"jsonrpc"`
-    Result  interface{} `json:"result"`
-    Error   struct {
-        Code    int    `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
-
-type rpcFilterChanges struct {
-    LogIndex         string   `json:"logIndex"`
-    TransactionHash  string   `json:"transactionHash"`
-    TransactionIndex string   `json:"transactionIndex"`
-    BlockNumber      string   `json:"blockNumber"`
-    BlockHash        string   `json:"blockHash"`
-    Address          string   `json:"address"`
-    Data             string   `json:"data"`
-    Topics           []string `json:"topics"`
-}
-
-type rpcGetFilterChangesResponse struct {
-    Id      string             `json:"id"`
-    Version string             `json:"jsonrpc"`
-    Result  []rpcFilterChanges `json:"result"`
-    Error   struct {
-        Code    int    `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
-
 func generateAgreementId(random *rand.Rand) []byte {
 
     b := make([]byte, 32, 32)
@@ -1,10 +1,11 @@
 package main
 
 import (
+    "context"
     "fmt"
-    "encoding/json"
     "log"
     "github.com/open-horizon/go-solidity/contract_api"
+    "github.com/open-horizon/go-solidity/directory"
     "os"
     "strings"
     "time"
@@ -208,16 +209,17 @@ func main() {
         os.Exit(1)
     }
 
-    // fmt.Printf("Retrieve a list of all registered names, should have MTN contracts plus 'a,c,c' in it.\n")
-    // p = make([]interface{},0,10)
-    // p = append(p,0)
-    // p = append(p,10)
-    // if nl,err := dirc.Invoke_method("get_names",p); err == nil {
-    //     fmt.Printf("Registered names %v\n",nl)
-    // } else {
-    //     fmt.Printf("Error invoking get_names: %v\n",err)
-    //     os.Exit(1)
-    // }
+    // The contract can't list its names, so the list is rebuilt from its events.
+    fmt.Printf("Retrieve a list of all registered names, should have MTN contracts plus 'a,b,c' in it.\n")
+    if index,err := directory.DirectoryFactory(dirc).Snapshot(context.Background(), 1, 0); err == nil {
+        fmt.Printf("Registered names %v\n",index.Names())
+        for _, e := range index.Entries(true) {
+            fmt.Printf("Entry %v version %v at %v by %v, deleted %v\n",e.Name,e.Version,e.Contract,e.Owner,e.Deleted != nil)
+        }
+    } else {
+        fmt.Printf("Error replaying the directory: %v\n",err)
+        os.Exit(1)
+    }
 
     fmt.Printf("Delete 'c' version 0.\n")
     p = make([]interface{},0,10)
@@ -255,45 +257,28 @@ func main() {
     // Find all events related to the directory test in the blockchain and dump them into the output.
 
     log.Printf("Dumping blockchain event data for contract %v.\n",dirc.Get_contract_address())
-    result, out, err := "", "", error(nil)
-    var rpcResp *rpcResponse = new(rpcResponse)
-
-    params := make(map[string]string)
-    params["address"] = dirc.Get_contract_address()
-    params["fromBlock"] = "0x1"
-
-    if out, err = dirc.Call_rpc_api("eth_newFilter", params); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("eth_newFilter returned an error: %v.\n", rpcResp.Error.Message)
-            } else {
-                result = rpcResp.Result.(string)
-                // log.Printf("Event id: %v.\n",result)
-            }
-        }
+    ec := dirc.Get_client()
+    events := make([]contract_api.Log, 0, 10)
+
+    query := &contract_api.FilterQuery{Address: dirc.Get_contract_address()}
+
+    if current, err := ec.Block_number(context.Background()); err != nil {
+        log.Printf("eth_blockNumber returned an error: %v.\n", err)
+    } else if err := ec.Get_logs_paged(context.Background(), query, 1, current, 0, func(logs []contract_api.Log) error {
+        events = append(events, logs...)
+        return nil
+    }); err != nil {
+        log.Printf("Error calling getLogs: %v.\n",err)
     }
 
-    var rpcFilterResp *rpcGetFilterChangesResponse = new(rpcGetFilterChangesResponse)
-    if out, err = dirc.Call_rpc_api("eth_getFilterLogs", result); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcFilterResp); err == nil {
-            if rpcFilterResp.Error.Message != "" {
-                log.Printf("eth_getFilterChanges returned an error: %v.\n", rpcFilterResp.Error.Message)
-            }
-        }
-    } else {
-        log.Printf("Error calling getFilterLogs: %v.\n",err)
-    }
-
-    if len(rpcFilterResp.Result) > 0 {
-        for ix, ev := range rpcFilterResp.Result {
-            format_dirc_event(ix, ev);
-        }
+    for ix, ev := range events {
+        format_dirc_event(ix, ev);
     }
 
     fmt.Println("Terminating directory test client")
 }
 
-func format_dirc_event(ix int, ev rpcFilterChanges) {
+func format_dirc_event(ix int, ev contract_api.Log) {
     // These event string correspond to event codes from the container_executor contract
     dirc_add_ev := "0x0000000000000000000000000000000000000000000000000000000000000000"
     dirc_del_ev := "0x0000000000000000000000000000000000000000000000000000000000000001"
@@ -311,34 +296,3 @@ func format_dirc_event(ix int, ev rpcFilterChanges) {
         log.Printf("Raw log entry:\n%v\n\n",ev)
     }
 }
-
-type rpcResponse struct {
-    Id      string      `json:"id"`
-    Version string      `json:"jsonrpc"`
-    Result  interface{} `json:"result"`
-    Error   struct {
-        Code    int    `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
-
-type rpcFilterChanges struct {
-    LogIndex         string   `json:"logIndex"`
-    TransactionHash  string   `json:"transactionHash"`
-    TransactionIndex string   `json:"transactionIndex"`
-    BlockNumber      string   `json:"blockNumber"`
-    BlockHash        string   `json:"blockHash"`
-    Address          string   `json:"address"`
-    Data             string   `json:"data"`
-    Topics           []string `json:"topics"`
-}
-
-type rpcGetFilterChangesResponse struct {
-    Id      string             `json:"id"`
-    Version string             `json:"jsonrpc"`
-    Result  []rpcFilterChanges `json:"result"`
-    Error   struct {
-        Code    int    `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
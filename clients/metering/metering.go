@@ -2,11 +2,11 @@ package main
 
 import (
     //"bytes"
+    "context"
     "fmt"
     "encoding/hex"
-    "encoding/json"
     "github.com/open-horizon/go-solidity/contract_api"
-    "golang.org/x/crypto/sha3"
+    "github.com/open-horizon/go-solidity/metering"
     "log"
     "math/big"
     "math/rand"
@@ -76,35 +76,20 @@ func main() {
     log.Printf("Hash and sign a simple string.\n")
     smarter_contract := "{long string of contract terms}"
     hash_string := "0x" + hex.EncodeToString([]byte(smarter_contract))
-    var rpcResp *rpcResponse = new(rpcResponse)
-    sig_hash, sig, out := "", "", ""
+    ec := ag.Get_client()
+    sig_hash, sig := "", ""
 
-    if out, err = ag.Call_rpc_api("web3_sha3", hash_string); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("RPC hash of terms and conditions failed, error: %v.", rpcResp.Error.Message)
-                os.Exit(1)
-            } else {
-                sig_hash = rpcResp.Result.(string)
-                log.Printf("Hash of terms and conditions is: %v\n", sig_hash)
-            }
-        }
+    if sig_hash, err = ec.Sha3(context.Background(), hash_string); err != nil {
+        log.Printf("RPC hash of terms and conditions failed, error: %v.", err)
+        os.Exit(1)
     }
+    log.Printf("Hash of terms and conditions is: %v\n", sig_hash)
 
-    if out, err = ag.Call_rpc_api("eth_sign", contract_api.MultiValueParams{owner, sig_hash}); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("RPC sign of terms and conditions hash failed, error: %v.", rpcResp.Error.Message)
-                os.Exit(1)
-            } else {
-                sig = rpcResp.Result.(string)
-                log.Printf("Signature of terms and conditions hash is: %v\n", sig)
-            }
-        } else {
-            log.Printf("Unmarshal error: %v.", err)
-            os.Exit(1)
-        }
+    if sig, err = ec.Sign(context.Background(), owner, sig_hash); err != nil {
+        log.Printf("RPC sign of terms and conditions hash failed, error: %v.", err)
+        os.Exit(1)
     }
+    log.Printf("Signature of terms and conditions hash is: %v\n", sig)
 
     if len(sig[2:]) != 130 {
         log.Printf("Signature has wrong length: %v.", len(sig[2:]))
@@ -259,40 +244,20 @@ func main() {
     // The events should match the sequence of operations that occurred above.
 
     log.Printf("Dumping blockchain event data for contract %v.\n",ag.Get_contract_address())
-    out, err = "", error(nil)
-    rpcResp = new(rpcResponse)
+    err = error(nil)
     result := ""
+    var events []contract_api.Log
 
-    params := make(map[string]string)
-    params["address"] = ag.Get_contract_address()
-    params["fromBlock"] = "0x1"
-
-    if out, err = ag.Call_rpc_api("eth_newFilter", params); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("eth_newFilter returned an error: %v.\n", rpcResp.Error.Message)
-            } else {
-                result = rpcResp.Result.(string)
-                // log.Printf("Event id: %v.\n",result)
-            }
-        }
-    }
+    query := &contract_api.FilterQuery{Address: ag.Get_contract_address(), FromBlock: "0x1"}
 
-    rpcFilterResp := new(rpcGetFilterChangesResponse)
-    if out, err = ag.Call_rpc_api("eth_getFilterLogs", result); err == nil {
-        if err = json.Unmarshal([]byte(out), rpcFilterResp); err == nil {
-            if rpcFilterResp.Error.Message != "" {
-                log.Printf("eth_getFilterChanges returned an error: %v.\n", rpcFilterResp.Error.Message)
-            }
-        }
-    } else {
+    if result, err = ec.New_filter(context.Background(), query); err != nil {
+        log.Printf("eth_newFilter returned an error: %v.\n", err)
+    } else if events, err = ec.Get_filter_logs(context.Background(), result); err != nil {
         log.Printf("Error calling getFilterLogs: %v.\n",err)
     }
 
-    if len(rpcFilterResp.Result) > 0 {
-        for ix, ev := range rpcFilterResp.Result {
-            format_m_event(ix, ev, tr);
-        }
+    for ix, ev := range events {
+        format_m_event(ix, ev, tr);
     }
 
     // Verify the results
@@ -512,7 +477,7 @@ func terminate_meter(ag *contract_api.SolidityContract, agID []byte, counterPart
 
 // For an event that is found in the blockchain, format it and write it out to the
 // testcase log.
-func format_m_event(ix int, ev rpcFilterChanges, tr *TestResults) {
+func format_m_event(ix int, ev contract_api.Log, tr *TestResults) {
     // These event strings correspond to event codes from the agreements contract
     m_cr8        := "0x0000000000000000000000000000000000000000000000000000000000000000"
     m_cr8_detail := "0x0000000000000000000000000000000000000000000000000000000000000001"
@@ -560,38 +525,6 @@ func (t *TestResults) String() string {
     return fmt.Sprintf("Success: %v, Fraud: %v, Delete: %v", t.Successful, t.Fraud, t.Delete)
 }
 
-// Mappings of structures used in the ethereum RPC API
-type rpcResponse struct {
-    Id      string      `json:"id"`
-    Version string      `json:"jsonrpc"`
-    Result  interface{} `json:"result"`
-    Error   struct {
-        Code    int    `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
-
-type rpcFilterChanges struct {
-    LogIndex         string   `json:"logIndex"`
-    TransactionHash  string   `json:"transactionHash"`
-    TransactionIndex string   `json:"transactionIndex"`
-    BlockNumber      string   `json:"blockNumber"`
-    BlockHash        string   `json:"blockHash"`
-    Address          string   `json:"address"`
-    Data             string   `json:"data"`
-    Topics           []string `json:"topics"`
-}
-
-type rpcGetFilterChangesResponse struct {
-    Id      string             `json:"id"`
-    Version string             `json:"jsonrpc"`
-    Result  []rpcFilterChanges `json:"result"`
-    Error   struct {
-        Code    int    `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
-
 func generateAgreementId(random *rand.Rand) []byte {
 
     b := make([]byte, 32, 32)
@@ -602,36 +535,14 @@ func generateAgreementId(random *rand.Rand) []byte {
 }
 
 func getMeterHash(count uint64, time uint64, agid []byte) string {
-
-    theMeter := make([]byte, 0, 96)
-    theMeter = append(theMeter, toBuffer(count)...)
-    theMeter = append(theMeter, toBuffer(time)...)
-    theMeter = append(theMeter, agid...)
-
-    hash := sha3.Sum256(theMeter)
-    hash_string := "0x" + hex.EncodeToString(hash[:])
-
-    return hash_string
+    return "0x" + hex.EncodeToString(metering.Meter_hash(count, time, agid))
 }
 
 func getMeterSig(msc *contract_api.SolidityContract, meterHash string, owner string) string {
 
-    var rpcResp *rpcResponse = new(rpcResponse)
-
-    if out, err := msc.Call_rpc_api("eth_sign", contract_api.MultiValueParams{owner, meterHash}); err == nil {
-        if err := json.Unmarshal([]byte(out), rpcResp); err == nil {
-            if rpcResp.Error.Message != "" {
-                log.Printf("RPC sign failed, error: %v.", rpcResp.Error.Message)
-                os.Exit(1)
-            } else {
-                sig := rpcResp.Result.(string)
-                log.Printf("Signature of hash is: %v\n", sig)
-                return sig
-            }
-        } else {
-            log.Printf("Unmarshal error: %v.", err)
-            os.Exit(1)
-        }
+    if sig, err := msc.Get_client().Sign(context.Background(), owner, meterHash); err == nil {
+        log.Printf("Signature of hash is: %v\n", sig)
+        return sig
     } else {
         log.Printf("RPC sign failed, error: %v.", err)
     }
@@ -639,12 +550,3 @@ func getMeterSig(msc *contract_api.SolidityContract, meterHash string, owner str
     return ""
 
 }
-
-func toBuffer(seq uint64) []byte {
-    buf := make([]byte, 32)
-    for i := len(buf) - 1; seq != 0; i-- {
-        buf[i] = byte(seq & 0xff)
-        seq >>= 8
-    }
-    return buf
-}
@@ -1,37 +1,18 @@
 package main 
 
 import (
+    "context"
     "fmt"
-    "encoding/json"
     "github.com/open-horizon/go-solidity/contract_api"
     "os"
     "strconv"
     "time"
 )
 
-type rpcResponse struct {
-    Id string `json:"id"`
-    Version string `json:"jsonrpc"`
-    Result interface{} `json:"result"`
-    Error struct {
-        Code int `json:"code"`
-        Message string `json:"message"`
-    } `json:"error"`
-}
-
-type RPCError struct {
-    msg string
-}
-func (e *RPCError) Error() string { if e != nil {return e.msg} else {return ""} }
-
-
-
 func main() {
     fmt.Println("Waiting for node to complete the sync process.")
 
-    err := error(nil)
-    var res string
-    var rpcResp *rpcResponse = new(rpcResponse)
+    ctx := context.Background()
     net_done := false
     block_done := false
     sync_done := false
@@ -42,108 +23,57 @@ func main() {
     }
     poll_wait,_ = strconv.Atoi(os.Args[1])
 
-    for !net_done {
-        sc := contract_api.SolidityContractFactory("dummy")
-        if res,err = sc.Call_rpc_api("net_peerCount",nil); err != nil {
-            fmt.Printf("Treating, %v ,as a temporary error, retrying.\n",err)
-            time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-            continue
-        }
-        if err = json.Unmarshal([]byte(res),rpcResp); err != nil {
-            fmt.Printf("Treating, %v ,as a permanent error.\n",err)
-            os.Exit(1)
-        }
+    // Keep retrying transient errors, the node might not be up yet. Any error that gets past
+    // the retry policy is permanent.
+    ec := contract_api.EthClientFactory("")
+    ec.Set_retry_policy(&contract_api.RetryPolicy{MaxAttempts: -1, InitialBackoff: time.Second, MaxBackoff: time.Duration(poll_wait)*time.Second, Multiplier: 2.0, Jitter: 0.2})
 
-        if rpcResp.Error.Message != "" {
-            err = &RPCError{fmt.Sprintf("RPC invocation returned an error: %v.",rpcResp.Error.Message)}
+    for !net_done {
+        if peers, err := ec.Peer_count(ctx); err != nil {
             fmt.Printf("Treating, %v ,as a permanent error.\n",err)
             os.Exit(1)
         } else {
-            fmt.Printf("netPeering result: %v\n",rpcResp)
-            switch rpcResp.Result.(type) {
-                case string:
-                    if rpcResp.Result != "0x0" {
-                        net_done = true
-                    } else {
-                        fmt.Printf("Still syncing...\n")
-                        time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-                        continue
-                    }
-                default:
-                    fmt.Printf("Still syncing...\n")
-                    time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-                    continue
+            fmt.Printf("netPeering result: %v\n",peers)
+            if peers != 0 {
+                net_done = true
+            } else {
+                fmt.Printf("Still syncing...\n")
+                time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
             }
         }
     }
 
     for !block_done {
-        sc := contract_api.SolidityContractFactory("dummy")
-        if res,err = sc.Call_rpc_api("eth_blockNumber",nil); err != nil {
-            fmt.Printf("Treating, %v ,as a temporary error, retrying.\n",err)
-            time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-            continue
-        }
-        if err = json.Unmarshal([]byte(res),rpcResp); err != nil {
-            fmt.Printf("Treating, %v ,as a permanent error.\n",err)
-            os.Exit(1)
-        }
-
-        if rpcResp.Error.Message != "" {
-            err = &RPCError{fmt.Sprintf("RPC invocation returned an error: %v.",rpcResp.Error.Message)}
+        if block, err := ec.Block_number(ctx); err != nil {
             fmt.Printf("Treating, %v ,as a permanent error.\n",err)
             os.Exit(1)
         } else {
-            fmt.Printf("blockNumber result: %v\n",rpcResp)
-            switch rpcResp.Result.(type) {
-                case string:
-                    if rpcResp.Result != "0x0" {
-                        block_done = true
-                    } else {
-                        fmt.Printf("Still syncing...\n")
-                        time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-                        continue
-                    }
-                default:
-                    fmt.Printf("Still syncing...\n")
-                    time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-                    continue
+            fmt.Printf("blockNumber result: %v\n",block)
+            if block != 0 {
+                block_done = true
+            } else {
+                fmt.Printf("Still syncing...\n")
+                time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
             }
         }
     }
 
-
     for !sync_done {
-        sc := contract_api.SolidityContractFactory("dummy")
-        if res,err = sc.Call_rpc_api("eth_syncing",nil); err == nil {
-            if err = json.Unmarshal([]byte(res),rpcResp); err == nil {
-                if rpcResp.Error.Message != "" {
-                    err = &RPCError{fmt.Sprintf("RPC invocation returned an error: %v.",rpcResp.Error.Message)}
-                    fmt.Printf("Treating, %v ,as a permanent error.\n",err)
-                    os.Exit(1)
-                } else {
-                    fmt.Printf("syncing result: %v\n",rpcResp)
-                    switch rpcResp.Result.(type) {
-                        case bool:
-                            if rpcResp.Result == false {
-                                sync_done = true
-                            }
-                        default:
-                            fmt.Printf("Still syncing...\n")
-                            time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
-                    }
-                }
+        if status, err := ec.Syncing(ctx); err != nil {
+            fmt.Printf("Treating, %v ,as a permanent error.\n",err)
+            os.Exit(1)
+        } else {
+            fmt.Printf("syncing result: %v\n",status)
+            if !status.Syncing {
+                sync_done = true
             } else {
-                fmt.Printf("Treating, %v ,as a permanent error.\n",err)
-                os.Exit(1)
+                fmt.Printf("Still syncing...\n")
+                time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
             }
-        } else {
-            fmt.Printf("Treating, %v ,as a temporary error, retrying.\n",err)
-            time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
         }
     }
 
     fmt.Println("Node is synchronized.")
     os.Exit(0)
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,265 @@
+package contract_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// The layouts of compiler output that a contract can be loaded from.
+type ArtifactFormat int
+
+const (
+	FormatAuto         ArtifactFormat = iota // work out the format from the content
+	FormatCombinedJSON                       // solc --combined-json abi,bin[,bin-runtime,metadata]
+	FormatStandardJSON                       // the output of solc --standard-json
+	FormatTruffle                            // a Truffle build artifact, build/contracts/Name.json
+	FormatHardhat                            // a Hardhat artifact, artifacts/src/Name.sol/Name.json
+)
+
+func (f ArtifactFormat) String() string {
+	switch f {
+	case FormatCombinedJSON:
+		return "combined-json"
+	case FormatStandardJSON:
+		return "standard-json"
+	case FormatTruffle:
+		return "truffle"
+	case FormatHardhat:
+		return "hardhat"
+	}
+	return "auto"
+}
+
+// Load the compiled contract from an artifact in the given format. Artifacts that hold many
+// contracts, combined-json and standard-json, are searched the same way as by Load_artifact_file.
+func (self *SolidityContract) Load_artifact_as(r io.Reader, format ArtifactFormat) error {
+	jBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &LoadError{fmt.Sprintf("Unable to read contract artifact for %v, error: %v", self.name, err)}
+	}
+	result, err := self.parse_artifact_as(jBytes, format)
+	if err == nil {
+		self.compiledContract = result
+	}
+	return err
+}
+
+// The top level fields of all the supported formats, used to work out which one an artifact is in.
+type artifactProbe struct {
+	Format    string                     `json:"_format"`
+	Abi       json.RawMessage            `json:"abi"`
+	Bytecode  json.RawMessage            `json:"bytecode"`
+	Contracts map[string]json.RawMessage `json:"contracts"`
+}
+
+func detect_artifact_format(jBytes []byte) ArtifactFormat {
+	probe := new(artifactProbe)
+	if err := json.Unmarshal(jBytes, probe); err != nil {
+		return FormatCombinedJSON
+	}
+	if strings.HasPrefix(probe.Format, "hh-sol-artifact") {
+		return FormatHardhat
+	} else if len(probe.Abi) != 0 && len(probe.Bytecode) != 0 {
+		return FormatTruffle
+	}
+	// Combined-json maps "file:Name" to the contract, standard-json maps the file to a map of names.
+	for _, raw := range probe.Contracts {
+		entry := make(map[string]json.RawMessage)
+		json.Unmarshal(raw, &entry)
+		if _, ok := entry["abi"]; ok {
+			return FormatCombinedJSON
+		} else if _, ok := entry["bin"]; ok {
+			return FormatCombinedJSON
+		}
+		return FormatStandardJSON
+	}
+	return FormatCombinedJSON
+}
+
+func (self *SolidityContract) parse_artifact_as(jBytes []byte, format ArtifactFormat) (*ABI, error) {
+	if format == FormatAuto {
+		format = detect_artifact_format(jBytes)
+	}
+	self.logger.Debug("Debug", fmt.Sprintf("Loading %v artifact for %v", format, self.name))
+
+	var contracts map[string]*ABI
+	err := error(nil)
+	switch format {
+	case FormatCombinedJSON:
+		contracts, err = parse_combined_json(jBytes)
+	case FormatStandardJSON:
+		contracts, err = parse_standard_json(jBytes)
+	case FormatTruffle, FormatHardhat:
+		contracts, err = parse_single_artifact(jBytes)
+	default:
+		err = &LoadError{fmt.Sprintf("Unknown artifact format %v.", int(format))}
+	}
+	if err != nil {
+		return nil, &LoadError{fmt.Sprintf("Decode of %v artifact for contract %v returned an error: %v.", format, self.name, err)}
+	}
+
+	keys := make([]string, 0, len(contracts))
+	for key := range contracts {
+		keys = append(keys, key)
+	}
+	key, err := self.contract_key(keys)
+	if err != nil {
+		return nil, &LoadError{fmt.Sprintf("Decode of %v artifact did not find data for contract %v: %v", format, self.name, err)}
+	}
+	return contracts[key], nil
+}
+
+// Return the hex encoded bytecode with a 0x prefix, or an empty string if there is none.
+func hex_code(code string) string {
+	code = strings.TrimPrefix(code, "0x")
+	if code == "" {
+		return ""
+	}
+	return "0x" + code
+}
+
+// Decode an ABI that is either a JSON array or, as older compilers produce, a string holding one.
+func decode_abi(raw json.RawMessage) ([]abiDefEntry, error) {
+	var abi []abiDefEntry
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		raw = json.RawMessage(str)
+	}
+	err := json.Unmarshal(raw, &abi)
+	return abi, err
+}
+
+func parse_combined_json(jBytes []byte) (map[string]*ABI, error) {
+	var cc struct {
+		Contracts map[string]struct {
+			Abi        json.RawMessage `json:"abi"`
+			Bin        string          `json:"bin"`
+			BinRuntime string          `json:"bin-runtime"`
+			Metadata   string          `json:"metadata"`
+		} `json:"contracts"`
+	}
+	if err := json.Unmarshal(jBytes, &cc); err != nil {
+		return nil, err
+	}
+	res := make(map[string]*ABI)
+	for key, meta := range cc.Contracts {
+		abi, err := decode_abi(meta.Abi)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = &ABI{Code: "0x" + meta.Bin, RuntimeCode: hex_code(meta.BinRuntime), Metadata: meta.Metadata, ABIDefinition: abi}
+	}
+	return res, nil
+}
+
+func parse_standard_json(jBytes []byte) (map[string]*ABI, error) {
+	var out struct {
+		Errors []struct {
+			Severity         string `json:"severity"`
+			FormattedMessage string `json:"formattedMessage"`
+		} `json:"errors"`
+		Contracts map[string]map[string]struct {
+			Abi      json.RawMessage `json:"abi"`
+			Metadata string          `json:"metadata"`
+			Evm      struct {
+				Bytecode struct {
+					Object string `json:"object"`
+				} `json:"bytecode"`
+				DeployedBytecode struct {
+					Object string `json:"object"`
+				} `json:"deployedBytecode"`
+			} `json:"evm"`
+		} `json:"contracts"`
+	}
+	if err := json.Unmarshal(jBytes, &out); err != nil {
+		return nil, err
+	}
+	for _, e := range out.Errors {
+		if e.Severity == "error" {
+			return nil, &LoadError{fmt.Sprintf("the compiler reported %v", e.FormattedMessage)}
+		}
+	}
+	res := make(map[string]*ABI)
+	for file, contracts := range out.Contracts {
+		for name, c := range contracts {
+			abi, err := decode_abi(c.Abi)
+			if err != nil {
+				return nil, err
+			}
+			res[file+":"+name] = &ABI{Code: hex_code(c.Evm.Bytecode.Object), RuntimeCode: hex_code(c.Evm.DeployedBytecode.Object), Metadata: c.Metadata, ABIDefinition: abi}
+		}
+	}
+	return res, nil
+}
+
+// Truffle and Hardhat artifacts hold a single contract and use the same field names for the parts
+// used here. Truffle artifacts also carry the metadata, Hardhat keeps it in a separate build info file.
+func parse_single_artifact(jBytes []byte) (map[string]*ABI, error) {
+	var art struct {
+		ContractName     string          `json:"contractName"`
+		SourceName       string          `json:"sourceName"`
+		SourcePath       string          `json:"sourcePath"`
+		Abi              json.RawMessage `json:"abi"`
+		Bytecode         string          `json:"bytecode"`
+		DeployedBytecode string          `json:"deployedBytecode"`
+		Metadata         string          `json:"metadata"`
+	}
+	if err := json.Unmarshal(jBytes, &art); err != nil {
+		return nil, err
+	}
+	abi, err := decode_abi(art.Abi)
+	if err != nil {
+		return nil, err
+	}
+	source := art.SourceName
+	if source == "" {
+		source = art.SourcePath
+	}
+	key := art.ContractName
+	if source != "" {
+		key = source + ":" + art.ContractName
+	}
+	return map[string]*ABI{key: {Code: hex_code(art.Bytecode), RuntimeCode: hex_code(art.DeployedBytecode), Metadata: art.Metadata, ABIDefinition: abi}}, nil
+}
+
+// Pick this contract's key from the keys of an artifact. The contract key is used when it is set.
+// Otherwise the contract is found under name.sol:name, under its bare name, or under the one source
+// file with a matching contract name. Any other contract in the artifact is never picked in its
+// place, and a name found in several source files needs the contract key to tell them apart.
+func (self *SolidityContract) contract_key(keys []string) (string, error) {
+	sort.Strings(keys)
+	has := func(k string) bool {
+		for _, key := range keys {
+			if key == k {
+				return true
+			}
+		}
+		return false
+	}
+	if self.contractKey != "" {
+		if has(self.contractKey) {
+			return self.contractKey, nil
+		}
+		return "", &LoadError{fmt.Sprintf("The artifact has no contract %v, it has %v.", self.contractKey, keys)}
+	} else if has(self.name + ".sol:" + self.name) {
+		return self.name + ".sol:" + self.name, nil
+	} else if has(self.name) {
+		return self.name, nil
+	}
+	matches := make([]string, 0, 1)
+	for _, key := range keys {
+		if strings.HasSuffix(key, ":"+self.name) {
+			matches = append(matches, key)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	} else if len(matches) > 1 {
+		return "", &LoadError{fmt.Sprintf("The artifact has contract %v in more than one source file, set the contract key to one of %v.", self.name, matches)}
+	}
+	return "", &LoadError{fmt.Sprintf("The artifact has no contract %v, it has %v.", self.name, keys)}
+}
@@ -0,0 +1,84 @@
+package contract_api
+
+import (
+	"strings"
+	"testing"
+)
+
+const testStandardJSON = `{"contracts": {"src/Token.sol": {
+	"Token": {"abi": [{"inputs": [], "type": "function", "stateMutability": "view", "name": "total", "outputs": [{"type": "uint256", "name": ""}]}],
+		"metadata": "{\"compiler\":{\"version\":\"0.8.19\"}}",
+		"evm": {"bytecode": {"object": "6080"}, "deployedBytecode": {"object": "6081"}}},
+	"Owned": {"abi": [], "evm": {"bytecode": {"object": "6082"}, "deployedBytecode": {"object": "6083"}}}}}}`
+
+const testTruffleArtifact = `{"contractName": "Token", "sourcePath": "/build/contracts/Token.sol",
+	"abi": [{"inputs": [], "type": "function", "constant": true, "name": "total", "outputs": [{"type": "uint256", "name": ""}]}],
+	"bytecode": "0x6080", "deployedBytecode": "0x6081", "metadata": "{\"compiler\":{\"version\":\"0.5.16\"}}"}`
+
+const testHardhatArtifact = `{"_format": "hh-sol-artifact-1", "contractName": "Token", "sourceName": "contracts/Token.sol",
+	"abi": [{"inputs": [], "type": "function", "stateMutability": "pure", "name": "total", "outputs": [{"type": "uint256", "name": ""}]}],
+	"bytecode": "0x6080", "deployedBytecode": "0x6081", "linkReferences": {}, "deployedLinkReferences": {}}`
+
+func TestArtifactFormats(t *testing.T) {
+	cases := []struct {
+		artifact string
+		format   ArtifactFormat
+		metadata bool
+	}{
+		{testStandardJSON, FormatStandardJSON, true},
+		{testTruffleArtifact, FormatTruffle, true},
+		{testHardhatArtifact, FormatHardhat, false},
+	}
+
+	for _, c := range cases {
+		if detected := detect_artifact_format([]byte(c.artifact)); detected != c.format {
+			t.Errorf("Detected format %v, expected %v\n", detected, c.format)
+		}
+		for _, format := range []ArtifactFormat{c.format, FormatAuto} {
+			sc := SolidityContractFactory("Token")
+			if err := sc.Load_artifact_as(strings.NewReader(c.artifact), format); err != nil {
+				t.Errorf("Loading %v artifact returned error: %v\n", format, err)
+				continue
+			}
+			abi := sc.compiledContract
+			if abi.Code != "0x6080" || abi.RuntimeCode != "0x6081" || (abi.Metadata != "") != c.metadata {
+				t.Errorf("Loading %v artifact returned code %v, runtime code %v, metadata %v\n", format, abi.Code, abi.RuntimeCode, abi.Metadata)
+			}
+			if !sc.is_constant("total") {
+				t.Errorf("Loading %v artifact, total should be constant\n", format)
+			}
+		}
+	}
+
+	sc := SolidityContractFactory("some_name")
+	sc.Set_contract_key("src/Token.sol:Owned")
+	if err := sc.Load_artifact_as(strings.NewReader(testStandardJSON), FormatStandardJSON); err != nil || sc.compiledContract.Code != "0x6082" {
+		t.Errorf("Loading a standard-json artifact by key returned error: %v\n", err)
+	}
+
+	sc = SolidityContractFactory("directory")
+	if err := sc.Load_artifact_file("../contracts/directory.json"); err != nil || sc.getFunctionFromABI("get_entry") == nil {
+		t.Errorf("Loading a combined-json artifact returned error: %v\n", err)
+	}
+}
+
+func TestArtifactContractKey(t *testing.T) {
+	// A truffle artifact holds a single contract, which is not loaded for another name.
+	sc := SolidityContractFactory("Other")
+	if err := sc.Load_artifact_as(strings.NewReader(testTruffleArtifact), FormatTruffle); err == nil {
+		t.Errorf("Loading the Token artifact as contract Other should have failed\n")
+	}
+
+	// A contract name in more than one source file is an error that lists them.
+	both := `{"contracts": {"src/a/Token.sol:Token": {"bin": "6060", "abi": []}, "src/b/Token.sol:Token": {"bin": "6061", "abi": []}}}`
+	sc = SolidityContractFactory("Token")
+	if err := sc.Load_artifact_as(strings.NewReader(both), FormatCombinedJSON); err == nil {
+		t.Errorf("Loading an artifact with two Token contracts should have failed\n")
+	} else if !strings.Contains(err.Error(), "src/a/Token.sol:Token") || !strings.Contains(err.Error(), "src/b/Token.sol:Token") {
+		t.Errorf("Loading an artifact with two Token contracts returned error %v, expected it to list both keys\n", err)
+	}
+	sc.Set_contract_key("src/b/Token.sol:Token")
+	if err := sc.Load_artifact_as(strings.NewReader(both), FormatCombinedJSON); err != nil || sc.compiledContract.Code != "0x6061" {
+		t.Errorf("Loading an artifact with two Token contracts by key returned error: %v\n", err)
+	}
+}
@@ -0,0 +1,87 @@
+package contract_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load the compiled contract from the artifact file at the given path, instead of looking for
+// name.json in the contract directory.
+func (self *SolidityContract) Load_artifact_file(path string) error {
+	jBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return &LoadError{fmt.Sprintf("Unable to read contract artifact %v, error: %v", path, err)}
+	}
+	return self.load_artifact(jBytes)
+}
+
+// Load the compiled contract from an artifact read from r.
+func (self *SolidityContract) Load_artifact_reader(r io.Reader) error {
+	jBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &LoadError{fmt.Sprintf("Unable to read contract artifact for %v, error: %v", self.name, err)}
+	}
+	return self.load_artifact(jBytes)
+}
+
+// Load the compiled contract from the artifact at the given path within fsys, e.g. an embed.FS
+// holding the contracts that were compiled into the program with go:embed.
+func (self *SolidityContract) Load_artifact_fs(fsys fs.FS, path string) error {
+	jBytes, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return &LoadError{fmt.Sprintf("Unable to read contract artifact %v, error: %v", path, err)}
+	}
+	return self.load_artifact(jBytes)
+}
+
+// Use the given ABI, as JSON, and hex encoded bytecode as the compiled contract.
+func (self *SolidityContract) Load_abi_bin(abi string, bin string) error {
+	result := new(ABI)
+	result.Code = "0x" + strings.TrimPrefix(bin, "0x")
+	if err := json.Unmarshal([]byte(abi), &result.ABIDefinition); err != nil {
+		return &LoadError{fmt.Sprintf("Unable to decode the ABI of contract %v, error: %v", self.name, err)}
+	}
+	self.compiledContract = result
+	return nil
+}
+
+// Use the given key to find the contract in a combined artifact, e.g. "contracts/Token.sol:Token".
+// Without a key the contract is found by its name, see contract_key.
+func (self *SolidityContract) Set_contract_key(key string) {
+	self.contractKey = key
+}
+
+func (self *SolidityContract) load_artifact(jBytes []byte) error {
+	result, err := self.parse_artifact(jBytes)
+	if err == nil {
+		self.compiledContract = result
+	}
+	return err
+}
+
+// Decode an artifact in any of the supported formats and return the ABI and code of this contract.
+func (self *SolidityContract) parse_artifact(jBytes []byte) (*ABI, error) {
+	return self.parse_artifact_as(jBytes, FormatAuto)
+}
+
+// Read the artifact for this contract. An explicit artifact file or file system takes precedence,
+// then name.json in the contract directory and finally the contracts directory of this package in
+// GOPATH.
+func (self *SolidityContract) get_precompiled_json() ([]byte, error) {
+	json_file := self.name + ".json"
+	if self.artifactFile != "" {
+		return ioutil.ReadFile(self.artifactFile)
+	} else if self.artifactFS != nil {
+		return fs.ReadFile(self.artifactFS, json_file)
+	} else if self.contractPath != "" {
+		// The contract path has always been used as a prefix, so it must end with a separator.
+		return ioutil.ReadFile(self.contractPath + json_file)
+	}
+	return ioutil.ReadFile(filepath.Join(os.Getenv("GOPATH"), "src/github.com/open-horizon/go-solidity/contracts", json_file))
+}
@@ -0,0 +1,49 @@
+package contract_api
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testArtifact = `{"version": "0.4.8", "contracts": {"src/Token.sol:Token": {"bin": "6060", "abi": "[{\"inputs\": [], \"type\": \"function\", \"constant\": true, \"name\": \"total\", \"outputs\": [{\"type\": \"uint256\", \"name\": \"r\"}]}]"}}}`
+
+func TestLoadArtifacts(t *testing.T) {
+	// The contract name doesn't match the file name, and the key isn't name.sol:name.
+	sc := SolidityContractFactory("Token")
+	if err := sc.Load_artifact_reader(strings.NewReader(testArtifact)); err != nil {
+		t.Fatalf("Load_artifact_reader returned error: %v\n", err)
+	} else if sc.compiledContract.Code != "0x6060" || sc.getFunctionFromABI("total") == nil {
+		t.Errorf("Load_artifact_reader loaded %v\n", sc.compiledContract)
+	}
+
+	fsys := fstest.MapFS{"token.json": &fstest.MapFile{Data: []byte(testArtifact)}, "Token.json": &fstest.MapFile{Data: []byte(testArtifact)}}
+	sc = SolidityContractFactory("token_contract")
+	sc.Set_contract_key("src/Token.sol:Token")
+	if err := sc.Load_artifact_fs(fsys, "token.json"); err != nil || sc.compiledContract.Code != "0x6060" {
+		t.Errorf("Load_artifact_fs returned error: %v\n", err)
+	}
+	sc.Set_contract_key("src/Other.sol:Other")
+	if err := sc.Load_artifact_fs(fsys, "token.json"); err == nil {
+		t.Errorf("Load_artifact_fs should have failed with an unknown contract key\n")
+	}
+
+	// The artifact file system is used by Load_contract.
+	sc, _ = SolidityContractFactoryWithOptions("Token", WithArtifactFS(fsys))
+	if _, err := sc.Load_contract("0x0000000000000000000000000000000000000001", ""); err != nil || sc.compiledContract.Code != "0x6060" {
+		t.Errorf("Load_contract from an artifact file system returned error: %v\n", err)
+	}
+
+	sc, _ = SolidityContractFactoryWithOptions("directory", WithArtifactFile("../contracts/directory.json"))
+	if _, err := sc.Load_contract("0x0000000000000000000000000000000000000001", ""); err != nil || sc.getFunctionFromABI("get_entry") == nil {
+		t.Errorf("Load_contract from an artifact file returned error: %v\n", err)
+	}
+
+	sc = SolidityContractFactory("Token")
+	if err := sc.Load_abi_bin(`[{"inputs": [], "type": "function", "constant": true, "name": "total", "outputs": []}]`, "0x6060"); err != nil || sc.compiledContract.Code != "0x6060" {
+		t.Errorf("Load_abi_bin returned error: %v\n", err)
+	}
+	if err := sc.Load_abi_bin(`not json`, "0x6060"); err == nil {
+		t.Errorf("Load_abi_bin should have failed decoding the ABI\n")
+	}
+}
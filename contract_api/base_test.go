@@ -1,9 +1,12 @@
 package contract_api
 
 import (
+    "context"
     "encoding/json"
+    "errors"
     "fmt"
     "math"
+    "net/http/httptest"
     "reflect"
     "runtime"
     "strconv"
@@ -567,6 +570,28 @@ func TestInvocationString(t *testing.T) {
 
 }
 
+func TestWaitForEventDeadline(t *testing.T) {
+    node := &fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x10", "eth_newFilter": "0x1", "eth_getFilterChanges": []interface{}{}}}
+    server := httptest.NewServer(node)
+    defer server.Close()
+
+    sc := SolidityContractFactory("some_contract")
+    sc.Set_rpcurl(server.URL)
+    sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+
+    ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+    defer cancel()
+
+    start := time.Now()
+    _, err := sc.Wait_for_event_ctx(ctx, []uint64{1}, "0xb37e8570f16682474894d435b207bb9a67dec3d9")
+    if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrTimeout) {
+        t.Errorf("Wait_for_event_ctx returned %v, expected %v\n", err, context.DeadlineExceeded)
+    }
+    if time.Now().Sub(start) > 2*time.Second {
+        t.Errorf("Wait_for_event_ctx did not return promptly after the deadline expired.\n")
+    }
+}
+
 func TestGlobalMethodHashCache(t *testing.T) {
     sc := SolidityContractFactory("some_contract")
     if err := json.Unmarshal([]byte(testCCJSONString),&sc.compiledContract); err != nil {
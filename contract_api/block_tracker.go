@@ -0,0 +1,104 @@
+package contract_api
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tracks the most recent block seen on a chain, so that contracts can tell when the chain has
+// stopped producing blocks and which block is stable enough to read from. All the contracts talking
+// to the same chain should share one tracker. By default a contract uses the tracker registered for
+// its node's URL, see Block_tracker_for.
+type BlockTracker struct {
+	lock          sync.Mutex
+	lastBlockTime int64  // The unix time in seconds when blockNumber was last updated
+	blockNumber   string // The last block that was seen
+}
+
+func BlockTrackerFactory() *BlockTracker {
+	return new(BlockTracker)
+}
+
+var block_trackers = make(map[string]*BlockTracker)
+var block_trackers_lock sync.Mutex
+
+// Return the tracker for the chain behind the given node URL, creating it on first use.
+func Block_tracker_for(url string) *BlockTracker {
+	block_trackers_lock.Lock()
+	defer block_trackers_lock.Unlock()
+	bt, ok := block_trackers[url]
+	if !ok {
+		bt = BlockTrackerFactory()
+		block_trackers[url] = bt
+	}
+	return bt
+}
+
+// Record a block that was seen on the chain.
+func (self *BlockTracker) update(blockNumber string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.blockNumber == "" || blockNumber != self.blockNumber {
+		self.lastBlockTime = time.Now().Unix()
+		self.blockNumber = blockNumber
+	}
+}
+
+// Return the last block that was seen.
+func (self *BlockTracker) Current() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.blockNumber
+}
+
+// Return the last block that was seen, less the given number of blocks.
+func (self *BlockTracker) stable(read_delay int) string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if len(self.blockNumber) <= 2 {
+		return ""
+	}
+	block, _ := strconv.ParseUint(self.blockNumber[2:], 16, 64)
+	if block >= uint64(read_delay) {
+		block = block - uint64(read_delay)
+	} else {
+		block = 0
+	}
+	return fmt.Sprintf("0x%x", block)
+}
+
+// Return the number of seconds since a new block was last seen.
+func (self *BlockTracker) age() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return int(time.Now().Unix() - self.lastBlockTime)
+}
+
+// Return true if no new block has been seen for the given number of seconds.
+func (self *BlockTracker) stopped(no_recent_blocks int) bool {
+	return self.age() >= no_recent_blocks
+}
+
+func (self *BlockTracker) String() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return fmt.Sprintf("{lastBlockTime: %v, blockNumber: %v}", self.lastBlockTime, self.blockNumber)
+}
+
+// Share the given tracker with the other contracts on the same chain.
+func (self *SolidityContract) Set_block_tracker(bt *BlockTracker) {
+	self.tracker = bt
+}
+
+// Return the tracker used by this contract; the one set explicitly, or the one registered for the
+// node's URL.
+func (self *SolidityContract) Get_block_tracker() *BlockTracker {
+	if self.tracker != nil {
+		return self.tracker
+	}
+	return Block_tracker_for(self.client.Get_rpcurl())
+}
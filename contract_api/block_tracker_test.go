@@ -0,0 +1,59 @@
+package contract_api
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestBlockTrackerPerChain(t *testing.T) {
+	chainA := httptest.NewServer(&fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x10"}})
+	defer chainA.Close()
+	chainB := httptest.NewServer(&fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x200"}})
+	defer chainB.Close()
+
+	a, _ := SolidityContractFactoryWithOptions("some_contract", WithRPCURL(chainA.URL), WithBlockReadDelay(2))
+	b, _ := SolidityContractFactoryWithOptions("some_contract", WithRPCURL(chainB.URL))
+
+	if stable := a.Get_stable_block(); stable != "0xe" {
+		t.Errorf("Chain A stable block is %v, expected 0xe\n", stable)
+	}
+	if stable := b.Get_stable_block(); stable != "0x200" {
+		t.Errorf("Chain B stable block is %v, expected 0x200\n", stable)
+	}
+	if a.Get_block_tracker() == b.Get_block_tracker() {
+		t.Errorf("Contracts on different chains share a block tracker\n")
+	}
+
+	// Contracts on the same chain share the tracker registered for the URL, unless given their own.
+	a2 := SolidityContractFactory("other_contract")
+	a2.Set_rpcurl(chainA.URL)
+	if a2.Get_block_tracker() != a.Get_block_tracker() {
+		t.Errorf("Contracts on the same chain don't share a block tracker\n")
+	}
+	bt := BlockTrackerFactory()
+	a3, _ := SolidityContractFactoryWithOptions("other_contract", WithRPCURL(chainA.URL), WithBlockTracker(bt))
+	if a3.Get_block_tracker() != bt || bt.Current() != "" {
+		t.Errorf("Contract did not use the tracker it was given\n")
+	}
+}
+
+func TestBlockTrackerConcurrency(t *testing.T) {
+	bt := BlockTrackerFactory()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bt.update("0x10")
+				bt.stable(i)
+				bt.stopped(300)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if bt.stopped(300) || bt.Current() != "0x10" {
+		t.Errorf("Tracker state is %v, expected block 0x10 seen recently\n", bt)
+	}
+}
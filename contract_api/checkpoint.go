@@ -0,0 +1,129 @@
+package contract_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The position of an event consumer on the chain; the last log it has processed.
+type Cursor struct {
+	Block    uint64 `json:"block"`
+	LogIndex uint64 `json:"logIndex"`
+}
+
+// Return true if the log comes after the cursor on the chain.
+func (self *Cursor) before(l *Log) bool {
+	block, index := Hex_to_uint64(l.BlockNumber), Hex_to_uint64(l.LogIndex)
+	return block > self.Block || (block == self.Block && index > self.LogIndex)
+}
+
+// Keeps the cursors of event consumers, by consumer name, so that they can resume where they left
+// off when the process restarts.
+type CheckpointStore interface {
+	// Return the saved cursor, or nil if none has been saved yet.
+	Load(name string) (*Cursor, error)
+	Save(name string, cursor Cursor) error
+}
+
+// A CheckpointStore keeping each cursor in a JSON file, named after the consumer, in a directory.
+// A cursor is written to a temporary file and renamed, so a crash never leaves a partial cursor.
+type FileCheckpointStore struct {
+	lock sync.Mutex
+	dir  string
+}
+
+func FileCheckpointStoreFactory(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, &CheckpointError{fmt.Sprintf("Unable to create checkpoint directory %v, error: %v", dir, err)}
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+func (self *FileCheckpointStore) Load(name string) (*Cursor, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	content, err := ioutil.ReadFile(self.file(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, &CheckpointError{fmt.Sprintf("Unable to read checkpoint %v, error: %v", self.file(name), err)}
+	}
+	return decode_cursor(name, content)
+}
+
+func (self *FileCheckpointStore) Save(name string, cursor Cursor) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	content, _ := json.Marshal(cursor)
+	file := self.file(name)
+	tmp, err := ioutil.TempFile(self.dir, name+".*.tmp")
+	if err == nil {
+		if _, err = tmp.Write(content); err == nil {
+			err = tmp.Sync()
+		}
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), file)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		return &CheckpointError{fmt.Sprintf("Unable to write checkpoint %v, error: %v", file, err)}
+	}
+	return nil
+}
+
+func (self *FileCheckpointStore) file(name string) string {
+	return filepath.Join(self.dir, name+".json")
+}
+
+// The operations needed from an embedded key value store, such as bbolt or badger, to keep
+// cursors in it. Get returns nil for a key that isn't there.
+type KVStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, value []byte) error
+}
+
+// A CheckpointStore keeping each cursor under prefix+name in a key value store.
+type KVCheckpointStore struct {
+	kv     KVStore
+	prefix string
+}
+
+func KVCheckpointStoreFactory(kv KVStore, prefix string) *KVCheckpointStore {
+	return &KVCheckpointStore{kv: kv, prefix: prefix}
+}
+
+func (self *KVCheckpointStore) Load(name string) (*Cursor, error) {
+	content, err := self.kv.Get([]byte(self.prefix + name))
+	if err != nil {
+		return nil, &CheckpointError{fmt.Sprintf("Unable to read checkpoint %v, error: %v", self.prefix+name, err)}
+	} else if content == nil {
+		return nil, nil
+	}
+	return decode_cursor(name, content)
+}
+
+func (self *KVCheckpointStore) Save(name string, cursor Cursor) error {
+	content, _ := json.Marshal(cursor)
+	if err := self.kv.Put([]byte(self.prefix+name), content); err != nil {
+		return &CheckpointError{fmt.Sprintf("Unable to write checkpoint %v, error: %v", self.prefix+name, err)}
+	}
+	return nil
+}
+
+func decode_cursor(name string, content []byte) (*Cursor, error) {
+	cursor := new(Cursor)
+	if err := json.Unmarshal(content, cursor); err != nil {
+		return nil, &CheckpointError{fmt.Sprintf("Checkpoint %v is not a cursor, error: %v", name, err)}
+	}
+	return cursor, nil
+}
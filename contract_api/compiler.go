@@ -0,0 +1,237 @@
+package contract_api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/open-horizon/go-solidity/utility"
+)
+
+// Compiles Solidity sources with a local solc binary. The artifacts are cached on disk, keyed by a
+// hash of the sources, the compiler version and the compiler settings, so a source is only compiled
+// again when it, or the way it is compiled, changes. A Compiler is safe for concurrent use.
+type Compiler struct {
+	SolcPath     string // The solc binary. Set by CompilerFactory.
+	Optimize     bool
+	OptimizeRuns int    // Passed to --optimize-runs when Optimize is set and this is not 0.
+	EVMVersion   string // Passed to --evm-version when set.
+	CacheDir     string // Where artifacts are cached. An empty CacheDir disables the cache.
+
+	lock    sync.Mutex
+	version string
+	logger  *utility.DebugTrace
+}
+
+// Create a compiler that uses the solc binary at the given path. An empty path means the binary
+// named by the mtn_solc environment variable, or solc on the PATH. Artifacts are cached in
+// go-solidity under the user's cache directory.
+func CompilerFactory(solc_path string) (*Compiler, error) {
+	if solc_path == "" {
+		solc_path = os.Getenv("mtn_solc")
+	}
+	if solc_path == "" {
+		solc_path = "solc"
+	}
+	path, err := exec.LookPath(solc_path)
+	if err != nil {
+		return nil, &CompileError{fmt.Sprintf("Unable to find the solc compiler %v, error: %v", solc_path, err)}
+	}
+
+	c := new(Compiler)
+	c.SolcPath = path
+	c.Optimize = true
+	if dir, err := os.UserCacheDir(); err == nil {
+		c.CacheDir = filepath.Join(dir, "go-solidity")
+	}
+	c.logger = utility.DebugTraceFactory(os.Getenv("mtn_soliditycontract"), "")
+	return c, nil
+}
+
+// Return the version reported by solc.
+func (self *Compiler) Version(ctx context.Context) (string, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.version == "" {
+		out, err := exec.CommandContext(ctx, self.SolcPath, "--version").Output()
+		if err != nil {
+			return "", &CompileError{fmt.Sprintf("Unable to run %v --version, error: %v", self.SolcPath, err)}
+		}
+		self.version = strings.TrimSpace(string(out))
+	}
+	return self.version, nil
+}
+
+// Compile the .sol file, or all the .sol files in the directory and below it, and return the
+// artifact in combined-json format. Contract keys are relative to the directory holding the
+// sources, e.g. agreements.sol:agreements.
+func (self *Compiler) Compile(ctx context.Context, source string) ([]byte, error) {
+	self.logger.Debug("Entry", source)
+
+	dir, files, err := sol_files(source)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--combined-json", "abi,bin,bin-runtime,metadata"}
+	if self.Optimize {
+		args = append(args, "--optimize")
+		if self.OptimizeRuns != 0 {
+			args = append(args, "--optimize-runs", strconv.Itoa(self.OptimizeRuns))
+		}
+	}
+	if self.EVMVersion != "" {
+		args = append(args, "--evm-version", self.EVMVersion)
+	}
+
+	cache_file := ""
+	if self.CacheDir != "" {
+		version, err := self.Version(ctx)
+		if err != nil {
+			return nil, err
+		}
+		key, err := source_hash(dir, files, version, args)
+		if err != nil {
+			return nil, err
+		}
+		cache_file = filepath.Join(self.CacheDir, key+".json")
+		if artifact, err := ioutil.ReadFile(cache_file); err == nil {
+			self.logger.Debug("Exit ", fmt.Sprintf("Using cached artifact %v", cache_file))
+			return artifact, nil
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, self.SolcPath, append(args, files...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	artifact, err := cmd.Output()
+	if err != nil {
+		err = &CompileError{fmt.Sprintf("Compile of %v failed, error: %v, %v", source, err, strings.TrimSpace(stderr.String()))}
+		self.logger.Debug("Error", err.Error())
+		return nil, err
+	}
+
+	if cache_file != "" {
+		// The artifact is written under a temporary name and renamed so that a concurrent reader
+		// never sees a partial file. A failure to cache is not a failure to compile.
+		if err := os.MkdirAll(self.CacheDir, 0755); err == nil {
+			tmp := fmt.Sprintf("%v.%v.tmp", cache_file, os.Getpid())
+			if err = ioutil.WriteFile(tmp, artifact, 0644); err == nil {
+				err = os.Rename(tmp, cache_file)
+			}
+			if err != nil {
+				self.logger.Debug("Debug", fmt.Sprintf("Unable to cache artifact %v, error: %v", cache_file, err))
+			}
+		}
+	}
+
+	self.logger.Debug("Exit ", source)
+	return artifact, nil
+}
+
+// Return the directory holding the sources and the sorted list of .sol files relative to it.
+func sol_files(source string) (string, []string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", nil, &CompileError{fmt.Sprintf("Unable to find Solidity source %v, error: %v", source, err)}
+	} else if !info.IsDir() {
+		return filepath.Dir(source), []string{filepath.Base(source)}, nil
+	}
+
+	files := make([]string, 0, 10)
+	err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".sol") {
+			rel, _ := filepath.Rel(source, path)
+			files = append(files, rel)
+		}
+		return err
+	})
+	if err != nil {
+		return "", nil, &CompileError{fmt.Sprintf("Unable to list Solidity sources in %v, error: %v", source, err)}
+	} else if len(files) == 0 {
+		return "", nil, &CompileError{fmt.Sprintf("No Solidity sources found in %v", source)}
+	}
+	sort.Strings(files)
+	return source, files, nil
+}
+
+// The path in an import directive, in any of its forms: import "p"; import "p" as x;
+// import * as x from "p"; import {a, b} from "p";
+var import_directive = regexp.MustCompile(`(?m)^\s*import\s+[^;]*?["']([^"']+)["']`)
+
+// Return the sources and, transitively, the files they import, relative to dir and sorted. An
+// import starting with . is relative to the importing file, any other is relative to dir, the way
+// solc resolves them when it is run in dir. Imports that can't be found are left out, since solc
+// would fail to compile them anyway, unless they are remapped.
+func import_closure(dir string, files []string) []string {
+	seen := make(map[string]bool)
+	queue := append([]string{}, files...)
+	for len(queue) > 0 {
+		file := filepath.Clean(queue[0])
+		queue = queue[1:]
+		if seen[file] {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		seen[file] = true
+		for _, m := range import_directive.FindAllStringSubmatch(string(content), -1) {
+			if strings.HasPrefix(m[1], ".") {
+				queue = append(queue, filepath.Join(filepath.Dir(file), m[1]))
+			} else {
+				queue = append(queue, m[1])
+			}
+		}
+	}
+
+	res := make([]string, 0, len(seen))
+	for file := range seen {
+		res = append(res, file)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Return a hash of everything that affects the compiler output: the compiler, its settings, and
+// the content of the sources and of every file they import, wherever it is.
+func source_hash(dir string, files []string, version string, args []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\n%v\n", version, strings.Join(args, " "))
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			return "", &CompileError{fmt.Sprintf("Unable to read Solidity source %v, error: %v", file, err)}
+		}
+	}
+	for _, file := range import_closure(dir, files) {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return "", &CompileError{fmt.Sprintf("Unable to read Solidity source %v, error: %v", file, err)}
+		}
+		fmt.Fprintf(h, "%v %v\n", file, len(content))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Compile the contract from the given .sol file or directory with the compiler, instead of loading
+// precompiled JSON. The source is compiled, or the cached artifact used, when the contract is
+// loaded or deployed.
+func (self *SolidityContract) Set_compiler(compiler *Compiler, source string) {
+	self.compiler = compiler
+	self.source = source
+}
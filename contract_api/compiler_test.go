@@ -0,0 +1,102 @@
+package contract_api
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A stand-in for solc that records each compile and prints a combined-json artifact for every
+// source it is given.
+const fakeSolc = `#!/bin/sh
+if [ "$1" = "--version" ]; then echo "solc, the solidity compiler commandline interface"; echo "Version: 0.4.8"; exit 0; fi
+echo "$@" >> "$(dirname "$0")/compiles"
+printf '{"version": "0.4.8", "contracts": {'
+sep=""
+for f in "$@"; do
+  case "$f" in *.sol) n=$(basename "$f" .sol); printf '%s"%s:%s": {"abi": "[]", "bin": "6060", "bin-runtime": "6061"}' "$sep" "$f" "$n"; sep=",";; esac
+done
+printf '}}'
+`
+
+func TestCompiler(t *testing.T) {
+	dir := t.TempDir()
+	solc := filepath.Join(dir, "solc")
+	if err := ioutil.WriteFile(solc, []byte(fakeSolc), 0755); err != nil {
+		t.Fatalf("Unable to write fake solc, error: %v\n", err)
+	}
+	src := filepath.Join(dir, "src")
+	os.Mkdir(src, 0755)
+	ioutil.WriteFile(filepath.Join(src, "token.sol"), []byte("contract token {}"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "owned.sol"), []byte("contract owned {}"), 0644)
+
+	compiler, err := CompilerFactory(solc)
+	if err != nil {
+		t.Fatalf("CompilerFactory returned error: %v\n", err)
+	}
+	compiler.CacheDir = filepath.Join(dir, "cache")
+	compiles := func() int {
+		out, _ := ioutil.ReadFile(filepath.Join(dir, "compiles"))
+		return strings.Count(string(out), "\n")
+	}
+
+	sc, _ := SolidityContractFactoryWithOptions("token", WithCompiler(compiler, src))
+	if _, err := sc.Load_contract("0x0000000000000000000000000000000000000001", ""); err != nil {
+		t.Fatalf("Load_contract with a compiler returned error: %v\n", err)
+	} else if sc.compiledContract.Code != "0x6060" || sc.compiledContract.RuntimeCode != "0x6061" {
+		t.Errorf("Load_contract with a compiler loaded %v\n", sc.compiledContract)
+	}
+
+	// A compile can be cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := compiler.Compile(ctx, filepath.Join(src, "owned.sol")); err == nil {
+		t.Errorf("Compile with a cancelled context should have failed\n")
+	}
+	sc2, _ := SolidityContractFactoryWithOptions("owned", WithCompiler(compiler, filepath.Join(src, "owned.sol")))
+	if _, err := sc2.Load_contract_ctx(ctx, "0x0000000000000000000000000000000000000001", ""); err == nil {
+		t.Errorf("Load_contract_ctx with a cancelled context should have failed\n")
+	}
+
+	// The unchanged sources come from the cache.
+	if _, err := compiler.Compile(context.Background(), src); err != nil || compiles() != 1 {
+		t.Errorf("Compile of unchanged sources returned error %v after %v compiles, expected 1\n", err, compiles())
+	}
+
+	// A changed source is compiled again.
+	ioutil.WriteFile(filepath.Join(src, "token.sol"), []byte("contract token { uint x; }"), 0644)
+	if _, err := compiler.Compile(context.Background(), src); err != nil || compiles() != 2 {
+		t.Errorf("Compile of changed sources returned error %v after %v compiles, expected 2\n", err, compiles())
+	}
+
+	// So are the same sources with different settings.
+	compiler.OptimizeRuns = 1000
+	if artifact, err := compiler.Compile(context.Background(), filepath.Join(src, "owned.sol")); err != nil || compiles() != 3 || !strings.Contains(string(artifact), "owned.sol:owned") {
+		t.Errorf("Compile of a single file returned %v, error %v after %v compiles, expected 3\n", string(artifact), err, compiles())
+	}
+
+	// And so is a source whose imports changed, inside or outside its directory.
+	lib := filepath.Join(dir, "lib")
+	os.Mkdir(lib, 0755)
+	ioutil.WriteFile(filepath.Join(lib, "math.sol"), []byte("library math {}"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "owned.sol"), []byte("import {math} from \"../lib/math.sol\";\ncontract owned {}"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "sale.sol"), []byte("import \"./owned.sol\";\ncontract sale is owned {}"), 0644)
+	sale := filepath.Join(src, "sale.sol")
+	if _, err := compiler.Compile(context.Background(), sale); err != nil || compiles() != 4 {
+		t.Errorf("Compile of a source with imports returned error %v after %v compiles, expected 4\n", err, compiles())
+	}
+	if _, err := compiler.Compile(context.Background(), sale); err != nil || compiles() != 4 {
+		t.Errorf("Compile of an unchanged source with imports returned error %v after %v compiles, expected 4\n", err, compiles())
+	}
+	ioutil.WriteFile(filepath.Join(lib, "math.sol"), []byte("library math { uint constant one = 1; }"), 0644)
+	if _, err := compiler.Compile(context.Background(), sale); err != nil || compiles() != 5 {
+		t.Errorf("Compile after an imported file changed returned error %v after %v compiles, expected 5\n", err, compiles())
+	}
+
+	if _, err := CompilerFactory(filepath.Join(dir, "no_such_solc")); err == nil {
+		t.Errorf("CompilerFactory should have failed to find the compiler\n")
+	}
+}
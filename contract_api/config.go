@@ -0,0 +1,228 @@
+package contract_api
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/open-horizon/go-solidity/utility"
+)
+
+// The configuration of a SolidityContract. Timeouts are rounded down to whole seconds.
+type Config struct {
+	RPCURL              string            // The node to talk to, unless Client is set.
+	Client              *EthClient        // A client shared with other contracts, instead of a private client.
+	BlockTracker        *BlockTracker     // A tracker shared with the other contracts on the chain, instead of the one registered for the URL.
+	TxTimeout           time.Duration     // How long to wait for a transaction receipt. mtn_soliditycontract_txdelay
+	SyncTimeout         time.Duration     // How long to wait for the node to sync. mtn_soliditycontract_syncdelay
+	NoRecentBlocks      time.Duration     // How long without a new block before the node is considered stuck. mtn_soliditycontract_no_recent_blocks
+	BlockUpdateDelay    time.Duration     // How long the current block is cached. mtn_soliditycontract_block_update_delay
+	BlockReadDelay      int               // How many blocks behind the current block reads are made. mtn_soliditycontract_block_read_delay
+	MissingReceiptRetry int               // How many times a transaction is resubmitted when its receipt never shows up. mtn_soliditycontract_missing_receipt_retry
+	IntegrationTest     bool              // Don't require the node to have peers. mtn_soliditycontract_integration
+	LogStats            bool              // Log blockchain statistics after each deploy. mtn_soliditycontract_logstats
+	ContractPath        string            // The directory holding the precompiled contracts. mtn_contractpath
+	ContractKey         string            // The key of the contract within its artifact, when it isn't name.sol:name.
+	ArtifactFile        string            // The artifact file to load, instead of name.json in ContractPath.
+	ArtifactFS          fs.FS             // The file system holding name.json, e.g. an embed.FS, instead of ContractPath.
+	Libraries           map[string]string // The addresses of the libraries used by the contract, see Set_libraries.
+	VerifyCode          bool              // Check the code at the contract address before using it, see Set_verify_code.
+	PollInterval        time.Duration     // How often subscriptions poll the node for new logs. Not rounded.
+	Compiler            *Compiler         // Compile Source instead of loading precompiled JSON, see Set_compiler.
+	Source              string
+	Logger              *utility.DebugTrace
+}
+
+// Configures a SolidityContract created by SolidityContractFactoryWithOptions.
+type Option func(*Config)
+
+// Return the default configuration, overridden by any of the mtn_soliditycontract_* environment
+// variables that are set. A variable that isn't a number, or is out of range, is ignored, so the
+// configuration returned is always valid.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		RPCURL:              "http://localhost:8545",
+		TxTimeout:           180 * time.Second,
+		SyncTimeout:         180 * time.Second,
+		NoRecentBlocks:      300 * time.Second,
+		BlockUpdateDelay:    10 * time.Second,
+		BlockReadDelay:      0,
+		MissingReceiptRetry: 1,
+		PollInterval:        5 * time.Second,
+		IntegrationTest:     false,
+		LogStats:            os.Getenv("mtn_soliditycontract_logstats") != "",
+		ContractPath:        os.Getenv("mtn_contractpath"),
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_txdelay")); err == nil && v > 0 {
+		cfg.TxTimeout = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_syncdelay")); err == nil && v > 0 {
+		cfg.SyncTimeout = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_integration")); err == nil && v != 0 {
+		cfg.IntegrationTest = true
+	}
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_no_recent_blocks")); err == nil && v > 0 {
+		cfg.NoRecentBlocks = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_block_read_delay")); err == nil && v >= 0 {
+		cfg.BlockReadDelay = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_block_update_delay")); err == nil && v >= 0 {
+		cfg.BlockUpdateDelay = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("mtn_soliditycontract_missing_receipt_retry")); err == nil && v > 0 {
+		cfg.MissingReceiptRetry = v
+	}
+	return cfg
+}
+
+func (cfg *Config) validate() error {
+	if cfg.TxTimeout < time.Second || cfg.SyncTimeout < time.Second || cfg.NoRecentBlocks < time.Second {
+		return &ConfigError{fmt.Sprintf("The transaction, sync and no recent blocks timeouts must be at least a second, have %v, %v and %v.", cfg.TxTimeout, cfg.SyncTimeout, cfg.NoRecentBlocks)}
+	} else if cfg.BlockUpdateDelay < 0 || cfg.BlockReadDelay < 0 || cfg.MissingReceiptRetry < 0 {
+		return &ConfigError{fmt.Sprintf("The block update delay, block read delay and missing receipt retries must not be negative, have %v, %v and %v.", cfg.BlockUpdateDelay, cfg.BlockReadDelay, cfg.MissingReceiptRetry)}
+	} else if cfg.PollInterval <= 0 {
+		return &ConfigError{fmt.Sprintf("The poll interval must be positive, have %v.", cfg.PollInterval)}
+	}
+	return nil
+}
+
+// Replace the whole configuration, environment variables included.
+func WithConfig(c Config) Option {
+	return func(cfg *Config) { *cfg = c }
+}
+
+func WithRPCURL(url string) Option {
+	return func(cfg *Config) { cfg.RPCURL = url }
+}
+
+func WithClient(client *EthClient) Option {
+	return func(cfg *Config) { cfg.Client = client }
+}
+
+func WithBlockTracker(bt *BlockTracker) Option {
+	return func(cfg *Config) { cfg.BlockTracker = bt }
+}
+
+func WithTxTimeout(d time.Duration) Option {
+	return func(cfg *Config) { cfg.TxTimeout = d }
+}
+
+func WithSyncTimeout(d time.Duration) Option {
+	return func(cfg *Config) { cfg.SyncTimeout = d }
+}
+
+func WithNoRecentBlocks(d time.Duration) Option {
+	return func(cfg *Config) { cfg.NoRecentBlocks = d }
+}
+
+func WithBlockUpdateDelay(d time.Duration) Option {
+	return func(cfg *Config) { cfg.BlockUpdateDelay = d }
+}
+
+func WithBlockReadDelay(blocks int) Option {
+	return func(cfg *Config) { cfg.BlockReadDelay = blocks }
+}
+
+func WithMissingReceiptRetry(retries int) Option {
+	return func(cfg *Config) { cfg.MissingReceiptRetry = retries }
+}
+
+func WithIntegrationTest(on bool) Option {
+	return func(cfg *Config) { cfg.IntegrationTest = on }
+}
+
+func WithLogStats(on bool) Option {
+	return func(cfg *Config) { cfg.LogStats = on }
+}
+
+func WithContractPath(path string) Option {
+	return func(cfg *Config) { cfg.ContractPath = path }
+}
+
+func WithContractKey(key string) Option {
+	return func(cfg *Config) { cfg.ContractKey = key }
+}
+
+func WithArtifactFile(path string) Option {
+	return func(cfg *Config) { cfg.ArtifactFile = path }
+}
+
+func WithArtifactFS(fsys fs.FS) Option {
+	return func(cfg *Config) { cfg.ArtifactFS = fsys }
+}
+
+func WithLibraries(libs map[string]string) Option {
+	return func(cfg *Config) { cfg.Libraries = libs }
+}
+
+func WithVerifyCode(verify bool) Option {
+	return func(cfg *Config) { cfg.VerifyCode = verify }
+}
+
+func WithCompiler(compiler *Compiler, source string) Option {
+	return func(cfg *Config) { cfg.Compiler, cfg.Source = compiler, source }
+}
+
+func WithPollInterval(d time.Duration) Option {
+	return func(cfg *Config) { cfg.PollInterval = d }
+}
+
+func WithLogger(logger *utility.DebugTrace) Option {
+	return func(cfg *Config) { cfg.Logger = logger }
+}
+
+// Create a contract object configured by the options. Anything not set by an option comes from the
+// mtn_soliditycontract_* environment variables, or the defaults when those are not set either, so
+// SolidityContractFactory(name) and SolidityContractFactoryWithOptions(name) are equivalent.
+func SolidityContractFactoryWithOptions(name string, opts ...Option) (*SolidityContract, error) {
+	cfg := ConfigFromEnv()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	sc := new(SolidityContract)
+	sc.name = name
+	sc.client = cfg.Client
+	if sc.client == nil {
+		sc.client = EthClientFactory(cfg.RPCURL)
+	}
+	sc.tracker = cfg.BlockTracker
+	sc.compiledContract = nil
+	sc.baseBody = make(map[string]string)
+	sc.baseBody["jsonrpc"] = "2.0"
+	sc.baseBody["id"] = "1"
+	sc.logger = cfg.Logger
+	if sc.logger == nil {
+		sc.logger = utility.DebugTraceFactory(os.Getenv("mtn_soliditycontract"), "")
+	}
+	sc.tx_delay_toleration = int(cfg.TxTimeout / time.Second)
+	sc.sync_delay_toleration = int(cfg.SyncTimeout / time.Second)
+	if cfg.IntegrationTest {
+		sc.integration_test = 1
+	}
+	sc.noRecentBlocks = int(cfg.NoRecentBlocks / time.Second)
+	if cfg.LogStats {
+		sc.logBlockchainStats = "true"
+	}
+	sc.blockReadDelay = cfg.BlockReadDelay
+	sc.blockUpdateDelay = int(cfg.BlockUpdateDelay / time.Second)
+	sc.missingReceiptRetry = cfg.MissingReceiptRetry
+	sc.contractPath = cfg.ContractPath
+	sc.contractKey = cfg.ContractKey
+	sc.artifactFile = cfg.ArtifactFile
+	sc.artifactFS = cfg.ArtifactFS
+	sc.Set_libraries(cfg.Libraries)
+	sc.verifyCode = cfg.VerifyCode
+	sc.Set_compiler(cfg.Compiler, cfg.Source)
+	sc.pollInterval = cfg.PollInterval
+	sc.subscriptions = make(map[*Subscription]bool)
+	return sc, nil
+}
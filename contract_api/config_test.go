@@ -0,0 +1,49 @@
+package contract_api
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConfigOptions(t *testing.T) {
+	os.Setenv("mtn_soliditycontract_txdelay", "60")
+	os.Setenv("mtn_soliditycontract_block_read_delay", "2")
+	defer os.Unsetenv("mtn_soliditycontract_txdelay")
+	defer os.Unsetenv("mtn_soliditycontract_block_read_delay")
+
+	// The environment is the fallback for anything the options don't set.
+	sc, err := SolidityContractFactoryWithOptions("some_contract", WithRPCURL("http://node:8545"), WithBlockReadDelay(4))
+	if err != nil {
+		t.Fatalf("SolidityContractFactoryWithOptions returned error: %v\n", err)
+	}
+	if sc.tx_delay_toleration != 60 || sc.blockReadDelay != 4 || sc.Get_client().Get_rpcurl() != "http://node:8545" {
+		t.Errorf("Configured tx delay %v, block read delay %v, rpc url %v, expected 60, 4 and http://node:8545\n", sc.tx_delay_toleration, sc.blockReadDelay, sc.Get_client().Get_rpcurl())
+	}
+
+	// Instances are configured independently.
+	other, _ := SolidityContractFactoryWithOptions("some_contract", WithTxTimeout(5*time.Second))
+	if other.tx_delay_toleration != 5 || other.blockReadDelay != 2 || sc.tx_delay_toleration != 60 {
+		t.Errorf("Second instance has tx delay %v and block read delay %v, expected 5 and 2\n", other.tx_delay_toleration, other.blockReadDelay)
+	}
+
+	if _, err := SolidityContractFactoryWithOptions("some_contract", WithTxTimeout(0)); err == nil {
+		t.Errorf("A zero tx timeout should have been rejected\n")
+	} else if _, ok := err.(*ConfigError); !ok {
+		t.Errorf("Expected a ConfigError, got %v\n", err)
+	}
+}
+
+func TestConfigFromEnvOutOfRange(t *testing.T) {
+	for _, name := range []string{"txdelay", "syncdelay", "no_recent_blocks", "block_read_delay", "block_update_delay", "missing_receipt_retry"} {
+		os.Setenv("mtn_soliditycontract_"+name, "-5")
+		cfg := ConfigFromEnv()
+		if err := cfg.validate(); err != nil {
+			t.Errorf("ConfigFromEnv with a negative %v returned an invalid configuration: %v\n", name, err)
+		}
+		if sc := SolidityContractFactory("some_contract"); sc == nil {
+			t.Errorf("SolidityContractFactory with a negative %v returned nil\n", name)
+		}
+		os.Unsetenv("mtn_soliditycontract_" + name)
+	}
+}
@@ -0,0 +1,123 @@
+package contract_api
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Called by an event consumer with each event, in the order the events were emitted. An event is
+// only checkpointed once its handler returns nil.
+type ConsumerHandler func(ev *Event) error
+
+// Processes the events of a contract once each, across restarts. The consumer keeps its cursor, the
+// last log it processed, in a CheckpointStore under its name. When run, it catches up from the
+// cursor through eth_getLogs and then follows new logs through a filter on the node. The cursor
+// only moves past an event once the handler has succeeded; a handler error stops the consumer, and
+// the event is delivered again the next time the consumer is run. An event whose handler succeeded
+// is delivered again only if the process stops before the cursor is saved.
+//
+// A retraction, see EventFilter, moves the cursor back to before the retracted event once its
+// handler succeeds, so the events that replace it are delivered. Events processed before a restart
+// can't be retracted; set Confirmations in the filter to avoid processing events that may be undone.
+type EventConsumer struct {
+	name       string
+	contract   *SolidityContract
+	filter     EventFilter
+	store      CheckpointStore
+	startBlock uint64
+	cursor     *Cursor
+}
+
+func EventConsumerFactory(name string, contract *SolidityContract, filter EventFilter, store CheckpointStore) *EventConsumer {
+	return &EventConsumer{name: name, contract: contract, filter: filter, store: store}
+}
+
+// Set the block the consumer starts from when it has no checkpoint yet, by default block 0.
+func (self *EventConsumer) Set_start_block(block uint64) {
+	self.startBlock = block
+}
+
+// Return the last log processed, or nil if the consumer hasn't processed any.
+func (self *EventConsumer) Get_cursor() *Cursor {
+	return self.cursor
+}
+
+// Deliver the events to the handler until the context is cancelled or the handler fails, returning
+// the error that stopped the consumer.
+func (self *EventConsumer) Run(ctx context.Context, handler ConsumerHandler) error {
+	c := self.contract
+	c.logger.Debug("Entry", self.name)
+	err := error(nil)
+	var event *abiDefEntry
+	var topics [][]string
+	var follower *log_follower
+
+	if c.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before consuming events.\n")}
+	} else if err = c.ensure_verified(ctx); err == nil {
+		if event, topics, err = c.filter_topics(&self.filter); err == nil {
+			self.cursor, err = self.store.Load(self.name)
+		}
+	}
+
+	if err == nil {
+		query := FilterQuery{Address: c.contractAddress, Topics: topic_query(topics)}
+		if follower, err = start_log_follower(ctx, c.client, query, self.filter.Confirmations, c.logger); err == nil {
+			defer follower.stop(context.Background())
+
+			// Catch up to the block the filter starts from.
+			from := self.startBlock
+			if self.cursor != nil {
+				from = self.cursor.Block
+			}
+			err = c.client.Get_logs_paged(ctx, &query, from, follower.block, 0, func(logs []Log) error {
+				return self.consume(event, topics, follower.add(ctx, logs), handler)
+			})
+
+			for err == nil {
+				var logs []Log
+				if logs, err = follower.poll(ctx); err == nil {
+					if err = self.consume(event, topics, logs, handler); err == nil {
+						err = sleep_ctx(ctx, c.pollInterval)
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		c.logger.Debug("Error", err.Error())
+	}
+	c.logger.Debug("Exit ", self.cursor)
+	return err
+}
+
+// Hand the logs after the cursor, and the retractions of those before it, to the handler, saving
+// the cursor after each one.
+func (self *EventConsumer) consume(event *abiDefEntry, topics [][]string, logs []Log, handler ConsumerHandler) error {
+	for ix := range logs {
+		l := &logs[ix]
+		processed := self.cursor != nil && !self.cursor.before(l)
+		if !match_topics(topics, l.Topics) || processed != l.Removed {
+			continue
+		}
+		if err := handler(self.contract.decode_event(event, l)); err != nil {
+			return err
+		}
+		block, index := Hex_to_uint64(l.BlockNumber), Hex_to_uint64(l.LogIndex)
+		if !l.Removed {
+			self.cursor = &Cursor{Block: block, LogIndex: index}
+		} else if index > 0 {
+			self.cursor = &Cursor{Block: block, LogIndex: index - 1}
+		} else if block > 0 {
+			self.cursor = &Cursor{Block: block - 1, LogIndex: math.MaxUint64}
+		} else {
+			self.cursor = &Cursor{}
+		}
+		if err := self.store.Save(self.name, *self.cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// A key value store kept in a map.
+type mapKV map[string][]byte
+
+func (m mapKV) Get(key []byte) ([]byte, error) {
+	return m[string(key)], nil
+}
+
+func (m mapKV) Put(key []byte, value []byte) error {
+	m[string(key)] = value
+	return nil
+}
+
+func TestCheckpointStores(t *testing.T) {
+	file, err := FileCheckpointStoreFactory(t.TempDir())
+	if err != nil {
+		t.Fatalf("FileCheckpointStoreFactory returned error: %v\n", err)
+	}
+	for _, store := range []CheckpointStore{file, KVCheckpointStoreFactory(mapKV{}, "cursor/")} {
+		if cursor, err := store.Load("agreements"); err != nil || cursor != nil {
+			t.Errorf("%T Load of a new consumer returned %v, error %v\n", store, cursor, err)
+		}
+		for _, c := range []Cursor{{Block: 16, LogIndex: 2}, {Block: 17, LogIndex: 0}} {
+			if err := store.Save("agreements", c); err != nil {
+				t.Errorf("%T Save returned error: %v\n", store, err)
+			} else if cursor, err := store.Load("agreements"); err != nil || cursor == nil || *cursor != c {
+				t.Errorf("%T Load returned %v, error %v, expected %v\n", store, cursor, err, c)
+			}
+		}
+	}
+}
+
+func TestEventConsumer(t *testing.T) {
+	testLog := func(block string, index string) map[string]interface{} {
+		return map[string]interface{}{"blockNumber": block, "logIndex": index, "topics": []string{testTransferTopic, testTopic(testFrom), testTopic(testToA)}}
+	}
+	history := []map[string]interface{}{testLog("0x10", "0x0"), testLog("0x10", "0x1"), testLog("0x11", "0x0")}
+	node := &fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x12", "eth_newFilter": "0x1", "eth_getFilterChanges": []interface{}{}, "eth_uninstallFilter": true}}
+	node.funcs = map[string]func(params []interface{}) (interface{}, error){
+		"eth_getLogs": func(params []interface{}) (interface{}, error) {
+			q := params[0].(map[string]interface{})
+			from, to := Hex_to_uint64(q["fromBlock"].(string)), Hex_to_uint64(q["toBlock"].(string))
+			logs := make([]interface{}, 0, 3)
+			for _, l := range history {
+				if b := Hex_to_uint64(l["blockNumber"].(string)); b >= from && b <= to {
+					logs = append(logs, l)
+				}
+			}
+			return logs, nil
+		},
+	}
+	node.queue("eth_getFilterChanges", []interface{}{testLog("0x12", "0x0"), testLog("0x13", "0x0")})
+	server := httptest.NewServer(node)
+	defer server.Close()
+	sc := testEventContract(t, server.URL)
+	store := KVCheckpointStoreFactory(mapKV{}, "")
+
+	// The handler fails on the third event; the consumer stops after the second.
+	failure := errors.New("handler failed")
+	seen := make([]Cursor, 0, 10)
+	consumer := EventConsumerFactory("transfers", sc, EventFilter{Event: "Transfer"}, store)
+	err := consumer.Run(context.Background(), func(ev *Event) error {
+		c := Cursor{Hex_to_uint64(ev.Log.BlockNumber), Hex_to_uint64(ev.Log.LogIndex)}
+		if c.Block == 0x11 {
+			return failure
+		}
+		seen = append(seen, c)
+		return nil
+	})
+	if err != failure {
+		t.Errorf("Run returned %v, expected the handler's error\n", err)
+	}
+	if cursor, _ := store.Load("transfers"); cursor == nil || *cursor != (Cursor{0x10, 1}) {
+		t.Errorf("The checkpoint after a failed handler is %v, expected block 16 log 1\n", cursor)
+	}
+
+	// A new consumer resumes with the failed event and then follows the new ones.
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer = EventConsumerFactory("transfers", sc, EventFilter{Event: "Transfer"}, store)
+	err = consumer.Run(ctx, func(ev *Event) error {
+		seen = append(seen, Cursor{Hex_to_uint64(ev.Log.BlockNumber), Hex_to_uint64(ev.Log.LogIndex)})
+		if len(seen) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v, expected the context to be cancelled\n", err)
+	}
+	expected := []Cursor{{0x10, 0}, {0x10, 1}, {0x11, 0}, {0x12, 0}, {0x13, 0}}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("The consumers processed %v, expected %v\n", seen, expected)
+	}
+	if cursor, _ := store.Load("transfers"); cursor == nil || *cursor != (Cursor{0x13, 0}) {
+		t.Errorf("The final checkpoint is %v, expected block 19 log 0\n", cursor)
+	}
+}
+
+func TestEventConsumerRetraction(t *testing.T) {
+	sc := testEventContract(t, "http://localhost:1")
+	store := KVCheckpointStoreFactory(mapKV{}, "")
+	ec := EventConsumerFactory("retract", sc, EventFilter{Event: "Transfer"}, store)
+	ec.cursor = &Cursor{Block: 0x12, LogIndex: 0}
+
+	event, topics, _ := sc.filter_topics(&ec.filter)
+	testLog := func(block string, index string, removed bool) Log {
+		return Log{BlockNumber: block, LogIndex: index, Removed: removed, Topics: []string{testTransferTopic, testTopic(testFrom), testTopic(testToA)}}
+	}
+	seen := make([]string, 0, 4)
+	handler := func(ev *Event) error {
+		s := ev.Log.BlockNumber + "/" + ev.Log.LogIndex
+		if ev.Removed {
+			s = "-" + s
+		}
+		seen = append(seen, s)
+		return nil
+	}
+
+	// A retraction of a log after the cursor was never processed and is skipped; the others move the
+	// cursor back to before the retracted log, the first log of a block to the end of the block before.
+	logs := []Log{testLog("0x13", "0x0", true), testLog("0x12", "0x0", true), testLog("0x11", "0x2", true), testLog("0x11", "0x0", true)}
+	if err := ec.consume(event, topics, logs, handler); err != nil {
+		t.Fatalf("consume returned error: %v\n", err)
+	}
+	if expected := []string{"-0x12/0x0", "-0x11/0x2", "-0x11/0x0"}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("consume handled %v, expected %v\n", seen, expected)
+	}
+	expected := Cursor{Block: 0x10, LogIndex: math.MaxUint64}
+	if saved, _ := store.Load("retract"); saved == nil || *saved != expected {
+		t.Errorf("consume saved cursor %v, expected %v\n", saved, expected)
+	}
+
+	// The replacement logs are processed from the rewound cursor.
+	seen = seen[:0]
+	if err := ec.consume(event, topics, []Log{testLog("0x11", "0x0", false), testLog("0x12", "0x0", false)}, handler); err != nil {
+		t.Fatalf("consume returned error: %v\n", err)
+	}
+	if expected := []string{"0x11/0x0", "0x12/0x0"}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("consume handled %v, expected %v\n", seen, expected)
+	}
+	if expected := (Cursor{Block: 0x12, LogIndex: 0}); *ec.Get_cursor() != expected {
+		t.Errorf("consume moved the cursor to %v, expected %v\n", *ec.Get_cursor(), expected)
+	}
+}
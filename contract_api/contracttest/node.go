@@ -0,0 +1,165 @@
+// Package contracttest provides a fake ethereum node for testing code that uses contracts, without
+// running a real node.
+package contracttest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// A fake ethereum node that answers JSON-RPC requests, single or batched. Serve it with
+// httptest.NewServer. A request is answered, in order of preference:
+//
+//   - by calling the function in Funcs for its method with its params;
+//   - with a result queued for its method, or for its method and first param such as
+//     "eth_getFilterChanges 0x1", each queued result being returned once;
+//   - with the canned result in Results for its method;
+//   - for eth_call, with the output in Outputs for the method signature whose selector the call
+//     data starts with, or a revert when Outputs is set but has none;
+//   - for eth_getLogs, with Logs when it is set;
+//   - for web3_sha3, with the real keccak hash of its param.
+//
+// Anything else is answered with a method not found error. Batch responses are returned in reverse
+// order, to prove that responses are matched to requests by id. The fields may be set before the
+// node is served, or changed between requests.
+type Node struct {
+	Results map[string]interface{}
+	Funcs   map[string]func(params []interface{}) (interface{}, error)
+	Outputs map[string]string
+	Logs    []interface{}
+
+	lock     sync.Mutex
+	queued   map[string][]interface{}
+	params   map[string]interface{}
+	calls    map[string]int
+	requests int
+}
+
+// Queue a result for the method, or for the method and its first param, ahead of the canned one.
+func (self *Node) Queue(method string, result interface{}) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.queued == nil {
+		self.queued = make(map[string][]interface{})
+	}
+	self.queued[method] = append(self.queued[method], result)
+}
+
+// Return the params of the last request for the method, or nil if there was none.
+func (self *Node) Last_params(method string) interface{} {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.params[method]
+}
+
+// Return the number of requests for the method, counting each one in a batch.
+func (self *Node) Calls(method string) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.calls[method]
+}
+
+// Return the number of HTTP requests served, a batch counting as one.
+func (self *Node) Requests() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.requests
+}
+
+func (self *Node) answer(req map[string]interface{}) map[string]interface{} {
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req["id"]}
+	method, _ := req["method"].(string)
+	params, _ := req["params"].([]interface{})
+	if self.params == nil {
+		self.params = make(map[string]interface{})
+		self.calls = make(map[string]int)
+	}
+	self.params[method] = req["params"]
+	self.calls[method] += 1
+
+	key := method
+	if len(params) > 0 {
+		if first, ok := params[0].(string); ok && len(self.queued[method+" "+first]) > 0 {
+			key = method + " " + first
+		}
+	}
+
+	if fn, ok := self.Funcs[method]; ok {
+		if res, err := fn(params); err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = res
+		}
+	} else if q := self.queued[key]; len(q) > 0 {
+		resp["result"], self.queued[key] = q[0], q[1:]
+	} else if res, ok := self.Results[method]; ok {
+		resp["result"] = res
+	} else if method == "eth_call" && self.Outputs != nil {
+		data := ""
+		if len(params) > 0 {
+			call, _ := params[0].(map[string]interface{})
+			data, _ = call["data"].(string)
+		}
+		for sig, out := range self.Outputs {
+			if strings.HasPrefix(data, Selector(sig)) {
+				resp["result"] = out
+			}
+		}
+		if resp["result"] == nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": "execution reverted"}
+		}
+	} else if method == "eth_getLogs" && self.Logs != nil {
+		resp["result"] = self.Logs
+	} else if method == "web3_sha3" && len(params) > 0 {
+		b, _ := hex.DecodeString(strings.TrimPrefix(params[0].(string), "0x"))
+		resp["result"] = "0x" + Keccak(b)
+	} else {
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	return resp
+}
+
+func (self *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.requests += 1
+	body, _ := ioutil.ReadAll(r.Body)
+	var out interface{}
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		reqs := make([]map[string]interface{}, 0, 10)
+		json.Unmarshal(body, &reqs)
+		resps := make([]map[string]interface{}, 0, len(reqs))
+		for ix := len(reqs) - 1; ix >= 0; ix-- {
+			resps = append(resps, self.answer(reqs[ix]))
+		}
+		out = resps
+	} else {
+		req := make(map[string]interface{})
+		json.Unmarshal(body, &req)
+		out = self.answer(req)
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// Return the keccak hash of b as hex, without a 0x prefix.
+func Keccak(b []byte) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Return the 0x prefixed selector of a method signature such as "transfer(address,uint256)".
+func Selector(sig string) string {
+	return "0x" + Keccak([]byte(sig))[:8]
+}
+
+// Return the hex number, without a 0x prefix, left padded with zeros to a 32 byte ABI word.
+func Word(hex string) string {
+	return strings.Repeat("0", 64-len(hex)) + hex
+}
@@ -0,0 +1,149 @@
+package contract_api
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The prefix eth_sign puts in front of a 32 byte hash before signing it, and that the contracts put
+// in front of the hash they pass to ecrecover.
+const signed_message_prefix = "\x19Ethereum Signed Message:\n32"
+
+// The secp256k1 curve, y^2 = x^3 + 7 over the field of size p, with base point g of order n.
+var (
+	secp256k1_p, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	secp256k1_n, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	secp256k1_gx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	secp256k1_gy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+)
+
+// A point on the curve. The point at infinity is nil.
+type curve_point struct {
+	x *big.Int
+	y *big.Int
+}
+
+// Return the hash ecrecover is given by the contracts for a hash signed with eth_sign: the keccak
+// hash of the hash with the signed message prefix in front of it.
+func Signed_message_hash(hash []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signed_message_prefix))
+	h.Write(hash)
+	return h.Sum(nil)
+}
+
+// Return the address, 0x prefixed lower case hex, of the account that signed the 32 byte hash with
+// eth_sign, the way the verifySig functions of the agreements and metering contracts recover it. The
+// signature is r, s and v, 65 bytes; a v of 0 or 1 is taken as 27 or 28, as the contracts do. A
+// signature the contracts' ecrecover would return the zero address for is an error.
+func Recover_signer(hash []byte, sig []byte) (string, error) {
+	if len(hash) != 32 {
+		return "", &SignatureError{msg: fmt.Sprintf("Unable to recover the signer of hash %x, it is %v bytes long instead of 32.", hash, len(hash))}
+	}
+	return recover_digest(Signed_message_hash(hash), sig)
+}
+
+// Return the address of the account that signed the digest, the hash given to ecrecover.
+func recover_digest(digest []byte, sig []byte) (string, error) {
+	if len(sig) != 65 {
+		return "", &SignatureError{msg: fmt.Sprintf("Unable to recover the signer of digest %x, the signature is %v bytes long instead of 65.", digest, len(sig))}
+	}
+
+	r, s, v := new(big.Int).SetBytes(sig[0:32]), new(big.Int).SetBytes(sig[32:64]), sig[64]
+	if v < 27 {
+		v += 27
+	}
+	if v != 27 && v != 28 {
+		return "", &SignatureError{msg: fmt.Sprintf("Unable to recover the signer of digest %x, v is %v.", digest, v)}
+	} else if r.Sign() == 0 || r.Cmp(secp256k1_n) >= 0 || s.Sign() == 0 || s.Cmp(secp256k1_n) >= 0 {
+		return "", &SignatureError{msg: fmt.Sprintf("Unable to recover the signer of digest %x, r or s is out of range.", digest)}
+	}
+
+	// R is the point with x coordinate r, and the y coordinate whose parity v gives.
+	y := new(big.Int).Exp(r, big.NewInt(3), secp256k1_p)
+	y.Add(y, big.NewInt(7)).Mod(y, secp256k1_p)
+	y2 := new(big.Int).Set(y)
+	y.Exp(y, new(big.Int).Rsh(new(big.Int).Add(secp256k1_p, big.NewInt(1)), 2), secp256k1_p)
+	if new(big.Int).Exp(y, big.NewInt(2), secp256k1_p).Cmp(y2) != 0 {
+		return "", &SignatureError{msg: fmt.Sprintf("Unable to recover the signer of digest %x, r is not on the curve.", digest)}
+	} else if y.Bit(0) != uint(v-27) {
+		y.Sub(secp256k1_p, y)
+	}
+
+	// The public key is r^-1 (s R - e G).
+	e := new(big.Int).SetBytes(digest)
+	r_inv := new(big.Int).ModInverse(r, secp256k1_n)
+	u1 := new(big.Int).Mul(r_inv, s)
+	u1.Mod(u1, secp256k1_n)
+	u2 := new(big.Int).Mul(r_inv, e)
+	u2.Neg(u2).Mod(u2, secp256k1_n)
+	q := point_add(point_mul(&curve_point{x: r, y: y}, u1), point_mul(&curve_point{x: secp256k1_gx, y: secp256k1_gy}, u2))
+	if q == nil {
+		return "", &SignatureError{msg: fmt.Sprintf("Unable to recover the signer of digest %x, the public key is the point at infinity.", digest)}
+	}
+	return public_key_address(q), nil
+}
+
+// Return whether the signature of the hash is the signer's, as the verifySig functions of the
+// contracts would decide it.
+func Verify_signature(hash []byte, sig []byte, signer string) bool {
+	addr, err := Recover_signer(hash, sig)
+	return err == nil && strings.EqualFold(strings.TrimPrefix(addr, "0x"), strings.TrimPrefix(signer, "0x"))
+}
+
+// Return the address of the account with the public key: the last 20 bytes of the keccak hash of
+// its coordinates.
+func public_key_address(q *curve_point) string {
+	key := make([]byte, 64)
+	q.x.FillBytes(key[0:32])
+	q.y.FillBytes(key[32:64])
+	h := sha3.NewLegacyKeccak256()
+	h.Write(key)
+	return "0x" + hex.EncodeToString(h.Sum(nil)[12:])
+}
+
+func point_add(a *curve_point, b *curve_point) *curve_point {
+	if a == nil {
+		return b
+	} else if b == nil {
+		return a
+	}
+
+	var l *big.Int
+	if a.x.Cmp(b.x) == 0 {
+		if sum := new(big.Int).Add(a.y, b.y); sum.Mod(sum, secp256k1_p).Sign() == 0 {
+			return nil
+		}
+		// The tangent, 3x^2 / 2y.
+		l = new(big.Int).Mul(a.x, a.x)
+		l.Mul(l, big.NewInt(3))
+		l.Mul(l, new(big.Int).ModInverse(new(big.Int).Lsh(a.y, 1), secp256k1_p))
+	} else {
+		// The chord, (yb - ya) / (xb - xa).
+		dx := new(big.Int).Sub(b.x, a.x)
+		l = new(big.Int).Sub(b.y, a.y)
+		l.Mul(l, dx.ModInverse(dx.Mod(dx, secp256k1_p), secp256k1_p))
+	}
+	l.Mod(l, secp256k1_p)
+
+	x := new(big.Int).Mul(l, l)
+	x.Sub(x, a.x).Sub(x, b.x).Mod(x, secp256k1_p)
+	y := new(big.Int).Sub(a.x, x)
+	y.Mul(y, l).Sub(y, a.y).Mod(y, secp256k1_p)
+	return &curve_point{x: x, y: y}
+}
+
+func point_mul(a *curve_point, k *big.Int) *curve_point {
+	var res *curve_point
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		res = point_add(res, res)
+		if k.Bit(i) == 1 {
+			res = point_add(res, a)
+		}
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package contract_api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The addresses of the accounts with private keys 1 and 2.
+const (
+	testKey1Address = "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf"
+	testKey2Address = "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf"
+)
+
+// Sign the hash the way eth_sign does, with the private key d and the nonce k.
+func test_sign(d int64, k int64, hash []byte) []byte {
+	e := new(big.Int).SetBytes(Signed_message_hash(hash))
+	R := point_mul(&curve_point{x: secp256k1_gx, y: secp256k1_gy}, big.NewInt(k))
+	r := new(big.Int).Mod(R.x, secp256k1_n)
+	s := new(big.Int).Mul(r, big.NewInt(d))
+	s.Add(s, e).Mul(s, new(big.Int).ModInverse(big.NewInt(k), secp256k1_n)).Mod(s, secp256k1_n)
+	sig := make([]byte, 65)
+	r.FillBytes(sig[0:32])
+	s.FillBytes(sig[32:64])
+	sig[64] = byte(27 + R.y.Bit(0))
+	return sig
+}
+
+func TestRecoverSigner(t *testing.T) {
+	g := &curve_point{x: secp256k1_gx, y: secp256k1_gy}
+	for d, expected := range map[int64]string{1: testKey1Address, 2: testKey2Address} {
+		if addr := public_key_address(point_mul(g, big.NewInt(d))); addr != expected {
+			t.Errorf("The address of key %v is %v, expected %v\n", d, addr, expected)
+		}
+	}
+
+	hash := bytes.Repeat([]byte{0xcd}, 32)
+	parities := make(map[byte]bool)
+	for _, k := range []int64{12345, 67890, 13579, 24680} {
+		for d, signer := range map[int64]string{1: testKey1Address, 2: testKey2Address} {
+			sig := test_sign(d, k, hash)
+			parities[sig[64]] = true
+			if addr, err := Recover_signer(hash, sig); err != nil || addr != signer {
+				t.Errorf("Recover_signer returned %v %v for key %v, expected %v\n", addr, err, d, signer)
+			}
+
+			// The contracts tolerate a v of 0 or 1.
+			sig[64] -= 27
+			if !Verify_signature(hash, sig, signer) {
+				t.Errorf("Verify_signature rejected the signature by key %v with v %v\n", d, sig[64])
+			}
+		}
+	}
+	if len(parities) != 2 {
+		t.Errorf("The signatures only had v %v\n", parities)
+	}
+
+	sig := test_sign(1, 12345, hash)
+	if Verify_signature(bytes.Repeat([]byte{0xce}, 32), sig, testKey1Address) {
+		t.Errorf("Verify_signature accepted the signature of another hash\n")
+	} else if Verify_signature(hash, sig, testKey2Address) {
+		t.Errorf("Verify_signature accepted the signature of another account\n")
+	} else if !Verify_signature(hash, sig, "0x7E5F4552091A69125D5DFCB7B8C2659029395BDF") {
+		t.Errorf("Verify_signature rejected an upper case signer\n")
+	}
+
+	bad := map[string][]byte{
+		"short":   sig[:64],
+		"v of 29": append(append([]byte{}, sig[:64]...), 29),
+		"r of 0":  append(make([]byte, 32), sig[32:]...),
+		"s of n":  append(append(append([]byte{}, sig[:32]...), secp256k1_n.Bytes()...), 27),
+	}
+	for name, b := range bad {
+		if addr, err := Recover_signer(hash, b); err == nil {
+			t.Errorf("Recover_signer returned %v for a signature with %v, expected an error\n", addr, name)
+		} else if _, ok := err.(*SignatureError); !ok {
+			t.Errorf("Recover_signer returned %T for a signature with %v, expected a SignatureError\n", err, name)
+		}
+	}
+}
+
+// A personal_sign vector from the web3.js documentation of web3.eth.accounts.sign and recover: the
+// message "Some data" signed with private key 0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318.
+const (
+	vectorMessage   = "Some data"
+	vectorDigest    = "1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"
+	vectorSignature = "b91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+	vectorAddress   = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+)
+
+func TestRecoverSignerVector(t *testing.T) {
+	digest, _ := hex.DecodeString(vectorDigest)
+	sig, _ := hex.DecodeString(vectorSignature)
+
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%v%v", len(vectorMessage), vectorMessage)))
+	if !bytes.Equal(h.Sum(nil), digest) {
+		t.Fatalf("The prefixed hash of %q is %x, expected %v\n", vectorMessage, h.Sum(nil), vectorDigest)
+	}
+	if addr, err := recover_digest(digest, sig); err != nil || addr != vectorAddress {
+		t.Errorf("recover_digest returned %v %v, expected %v\n", addr, err, vectorAddress)
+	}
+
+	// Signatures of the wrong length or with a v that is not 0, 1, 27 or 28 are errors, not panics.
+	hash := bytes.Repeat([]byte{0xcd}, 32)
+	bad := map[string][]byte{"nil": nil, "0 bytes": {}, "64 bytes": sig[:64], "66 bytes": append(append([]byte{}, sig...), 0)}
+	for _, v := range []byte{2, 26, 29, 35, 255} {
+		b := append([]byte{}, sig...)
+		b[64] = v
+		bad[fmt.Sprintf("v of %v", v)] = b
+	}
+	for name, b := range bad {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("Recover_signer panicked for a signature with %v: %v\n", name, r)
+				}
+			}()
+			if addr, err := Recover_signer(hash, b); err == nil {
+				t.Errorf("Recover_signer returned %v for a signature with %v, expected an error\n", addr, name)
+			} else if _, ok := err.(*SignatureError); !ok {
+				t.Errorf("Recover_signer returned %T for a signature with %v, expected a SignatureError\n", err, name)
+			}
+		}()
+	}
+}
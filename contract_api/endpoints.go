@@ -0,0 +1,416 @@
+package contract_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/open-horizon/go-solidity/utility"
+)
+
+// The RPC methods that only read chain state. When read splitting is enabled these are sent to the
+// healthiest replica. Every other method, including the filter methods whose state lives in a single
+// node, is sent to the primary so that transaction nonces are assigned by one node in order.
+var read_methods = map[string]bool{
+	"eth_call":             true,
+	"eth_getLogs":          true,
+	"eth_getCode":          true,
+	"eth_getBalance":       true,
+	"eth_getBlockByNumber": true,
+	"eth_getBlockByHash":   true,
+	"web3_sha3":            true,
+}
+
+func is_read_method(method string) bool {
+	return read_methods[method]
+}
+
+// The number of blocks an endpoint can fall behind the most advanced endpoint and still be healthy.
+const max_endpoint_lag = 5
+
+// The number of consecutive failed calls or probes after which a reachable endpoint is considered
+// down. Each failure before that halves its score, so a degraded endpoint sorts behind healthy ones.
+const max_endpoint_failures = 3
+
+// The health of a single endpoint, as of the last probe or call.
+type EndpointStatus struct {
+	URL      string
+	Healthy  bool
+	Primary  bool
+	Score    int    // 0 (unusable) to 100 (fully healthy)
+	Block    uint64 // The last block number reported by the endpoint
+	Failures int    // Consecutive failed calls or probes
+}
+
+type endpoint struct {
+	url      string
+	healthy  bool
+	score    int
+	block    uint64
+	failures int
+}
+
+// A set of RPC endpoints for the same chain. One endpoint is the primary, which receives all
+// writes. When the primary fails, the healthiest remaining endpoint is promoted. Reads can optionally
+// be spread over the other healthy endpoints.
+type EndpointPool struct {
+	lock       sync.Mutex
+	endpoints  []*endpoint
+	primary    int
+	splitReads bool
+	nextRead   int
+	client     *http.Client
+	eth        *EthClient // The client routing through the pool, whose transport the probes use
+	logger     *utility.DebugTrace
+	stop       chan bool
+}
+
+// Create a pool from a list of endpoint URLs. The first URL is the initial primary. All endpoints
+// are assumed healthy until a probe or a call proves otherwise.
+func EndpointPoolFactory(urls []string, split_reads bool) (*EndpointPool, error) {
+	if len(urls) == 0 {
+		return nil, &RPCError{msg: "An endpoint pool needs at least one endpoint URL."}
+	}
+	pool := new(EndpointPool)
+	pool.splitReads = split_reads
+	pool.logger = utility.DebugTraceFactory(os.Getenv("mtn_soliditycontract"), "")
+	for _, url := range urls {
+		pool.endpoints = append(pool.endpoints, &endpoint{url: url, healthy: true, score: 100})
+	}
+	return pool, nil
+}
+
+// Use the given HTTP client for health probes, instead of that of the EthClient routing through the
+// pool.
+func (self *EndpointPool) Set_http_client(client *http.Client) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.client = client
+}
+
+// Return the URL of the current primary endpoint.
+func (self *EndpointPool) Primary() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.endpoints[self.primary].url
+}
+
+// Return a snapshot of the health of every endpoint in the pool.
+func (self *EndpointPool) Status() []EndpointStatus {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	res := make([]EndpointStatus, 0, len(self.endpoints))
+	for ix, ep := range self.endpoints {
+		res = append(res, EndpointStatus{URL: ep.url, Healthy: ep.healthy, Primary: ix == self.primary, Score: ep.score, Block: ep.block, Failures: ep.failures})
+	}
+	return res
+}
+
+// Return the endpoints to try for a call, in order. Writes start with the primary. Reads start with
+// a healthy replica when read splitting is enabled, rotating among the replicas with the best score.
+// The remaining healthy endpoints follow, best score first, and unhealthy endpoints come last so that
+// a call is still attempted when every endpoint looks down.
+func (self *EndpointPool) candidates(read_only bool) []string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	others := make([]int, 0, len(self.endpoints))
+	for ix := range self.endpoints {
+		if ix != self.primary {
+			others = append(others, ix)
+		}
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		a, b := self.endpoints[others[i]], self.endpoints[others[j]]
+		if a.healthy != b.healthy {
+			return a.healthy
+		}
+		return a.score > b.score
+	})
+
+	order := make([]int, 0, len(self.endpoints))
+	if read_only && self.splitReads && len(others) > 0 && self.endpoints[others[0]].healthy {
+		best := make([]int, 0, len(others))
+		for _, ix := range others {
+			if self.endpoints[ix].healthy && self.endpoints[ix].score == self.endpoints[others[0]].score {
+				best = append(best, ix)
+			}
+		}
+		self.nextRead = (self.nextRead + 1) % len(best)
+		order = append(order, best[self.nextRead])
+	}
+	order = append(order, self.primary)
+	order = append(order, others...)
+
+	res := make([]string, 0, len(order))
+	seen := make(map[int]bool)
+	for _, ix := range order {
+		if !seen[ix] {
+			seen[ix] = true
+			res = append(res, self.endpoints[ix].url)
+		}
+	}
+	return res
+}
+
+// Record the outcome of a call to an endpoint. A success restores some of the score lost to
+// failures, and a primary that goes down is replaced by the healthiest remaining endpoint.
+func (self *EndpointPool) report(url string, err error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for ix, ep := range self.endpoints {
+		if ep.url != url {
+			continue
+		}
+		if err == nil {
+			ep.failures = 0
+			if !ep.healthy {
+				ep.healthy, ep.score = true, 50
+			} else {
+				ep.score += (100 - ep.score) / 2
+			}
+		} else {
+			ep.failed(not_delivered(err))
+			if ix == self.primary {
+				self.promote()
+			}
+		}
+	}
+}
+
+// Record a failed call or probe. An endpoint that couldn't be reached at all is down at once. One
+// that answered badly is degraded, and down after max_endpoint_failures failures in a row.
+func (self *endpoint) failed(unreachable bool) {
+	self.failures += 1
+	self.score /= 2
+	if unreachable || self.failures >= max_endpoint_failures {
+		self.healthy, self.score = false, 0
+	}
+}
+
+// Make the healthiest endpoint the primary, if the current primary is unhealthy. Must be called with
+// the lock held.
+func (self *EndpointPool) promote() {
+	if self.endpoints[self.primary].healthy {
+		return
+	}
+	best := -1
+	for ix, ep := range self.endpoints {
+		if ep.healthy && (best == -1 || ep.score > self.endpoints[best].score) {
+			best = ix
+		}
+	}
+	if best != -1 {
+		self.logger.Debug("Debug", fmt.Sprintf("Failing over primary endpoint from %v to %v.", self.endpoints[self.primary].url, self.endpoints[best].url))
+		self.primary = best
+	}
+}
+
+// Probe every endpoint with the same checks that check_eth_status makes; peer count, block number
+// and sync state, and update the health scores. Endpoints that are syncing, have no peers or have
+// fallen more than a few blocks behind the most advanced endpoint are marked unhealthy, as are those
+// whose probes keep failing, and an unhealthy primary is replaced. The probes are sent with the HTTP
+// client of the EthClient routing through the pool, so they carry the same TLS settings and
+// authentication as its calls.
+func (self *EndpointPool) Check_health(ctx context.Context) {
+	self.lock.Lock()
+	urls := make([]string, 0, len(self.endpoints))
+	for _, ep := range self.endpoints {
+		urls = append(urls, ep.url)
+	}
+	client := self.client
+	if client == nil && self.eth != nil {
+		client = self.eth.http_client()
+	}
+	self.lock.Unlock()
+
+	if client == nil {
+		client = default_http_client
+	}
+
+	results := make([]*ethStatus, len(urls))
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for ix, url := range urls {
+		wg.Add(1)
+		go func(ix int, url string) {
+			defer wg.Done()
+			status, err := probe_endpoint(ctx, client, url)
+			if err != nil {
+				self.logger.Debug("Debug", fmt.Sprintf("Health probe of %v failed: %v", url, err))
+			}
+			results[ix], errs[ix] = status, err
+		}(ix, url)
+	}
+	wg.Wait()
+
+	var top uint64
+	for _, status := range results {
+		if status != nil && status.blockNumber() > top {
+			top = status.blockNumber()
+		}
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for ix, status := range results {
+		ep := self.endpoints[ix]
+		if status == nil {
+			ep.failed(not_delivered(errs[ix]))
+			continue
+		}
+		ep.failures = 0
+		ep.block = status.blockNumber()
+		ep.score = 100
+		if status.syncing {
+			ep.score -= 50
+		}
+		if status.peers == 0 {
+			ep.score -= 30
+		}
+		lag := top - ep.block
+		if lag > 20 {
+			lag = 20
+		}
+		ep.score -= int(lag) * 2
+		ep.healthy = !status.syncing && top-ep.block <= max_endpoint_lag
+	}
+	self.promote()
+}
+
+// Probe the endpoints periodically in the background until Stop_health_checks is called.
+func (self *EndpointPool) Start_health_checks(interval time.Duration) {
+	self.lock.Lock()
+	if self.stop != nil {
+		self.lock.Unlock()
+		return
+	}
+	stop := make(chan bool)
+	self.stop = stop
+	self.lock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			self.Check_health(ctx)
+			cancel()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (self *EndpointPool) Stop_health_checks() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+}
+
+func (self *ethStatus) blockNumber() uint64 {
+	if len(self.block) <= 2 {
+		return 0
+	}
+	num, _ := strconv.ParseUint(self.block[2:], 16, 64)
+	return num
+}
+
+// Retrieve the peer count, block number and sync state of a single endpoint in one round trip.
+func probe_endpoint(ctx context.Context, client *http.Client, url string) (*ethStatus, error) {
+	methods := []string{"net_peerCount", "eth_blockNumber", "eth_syncing"}
+	bodies := make([]map[string]interface{}, 0, len(methods))
+	for ix, m := range methods {
+		bodies = append(bodies, map[string]interface{}{"jsonrpc": "2.0", "id": ix + 1, "method": m, "params": []interface{}{}})
+	}
+	jsonBytes, _ := json.Marshal(bodies)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	outBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]struct {
+		Id     int         `json:"id"`
+		Result interface{} `json:"result"`
+	}, 0, len(methods))
+	if err = json.Unmarshal(outBytes, &responses); err != nil {
+		return nil, &RPCError{msg: fmt.Sprintf("Health probe of %v returned undecodable response %v, error: %v", url, string(outBytes), err)}
+	}
+
+	status := &ethStatus{syncing: true}
+	for _, r := range responses {
+		switch r.Id {
+		case 1:
+			if peers, ok := r.Result.(string); ok && len(peers) > 2 {
+				status.peers, _ = strconv.ParseUint(peers[2:], 16, 64)
+			}
+		case 2:
+			if block, ok := r.Result.(string); ok {
+				status.block = block
+			}
+		case 3:
+			if syncing, ok := r.Result.(bool); ok {
+				status.syncing = syncing
+			}
+		}
+	}
+	return status, nil
+}
+
+// Return true if the request never reached the endpoint, so it is safe to send it elsewhere even if
+// it is not idempotent.
+func not_delivered(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// Route calls through a pool of endpoints instead of the single RPC URL. The pool's health probes
+// use this client's HTTP client, unless the pool has its own.
+func (self *EthClient) Set_endpoints(pool *EndpointPool) {
+	self.endpoints = pool
+	if pool != nil {
+		pool.lock.Lock()
+		pool.eth = self
+		pool.lock.Unlock()
+	}
+}
+
+func (self *EthClient) Get_endpoints() *EndpointPool {
+	return self.endpoints
+}
+
+func (self *SolidityContract) Set_endpoints(pool *EndpointPool) {
+	self.client.Set_endpoints(pool)
+}
+
+func (self *SolidityContract) Get_endpoints() *EndpointPool {
+	return self.client.Get_endpoints()
+}
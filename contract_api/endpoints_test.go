@@ -0,0 +1,125 @@
+package contract_api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Count the requests that reach a fake node.
+type countingNode struct {
+	node  *fakeNode
+	count int
+}
+
+func (c *countingNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.count += 1
+	c.node.ServeHTTP(w, r)
+}
+
+func TestEndpointFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	up := &countingNode{node: &fakeNode{results: map[string]interface{}{"eth_newFilter": "0x1"}}}
+	server := httptest.NewServer(up)
+	defer server.Close()
+
+	pool, err := EndpointPoolFactory([]string{downURL, server.URL}, false)
+	if err != nil {
+		t.Fatalf("EndpointPoolFactory returned error: %v\n", err)
+	}
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_endpoints(pool)
+
+	// eth_newFilter is not a read, but the request never reached the dead endpoint so it is safe to
+	// send it to the next one.
+	if _, err := sc.Call_rpc_api("eth_newFilter", map[string]string{}); err != nil {
+		t.Fatalf("Call_rpc_api did not fail over, error: %v\n", err)
+	}
+	if pool.Primary() != server.URL {
+		t.Errorf("Primary is %v, expected failover to %v\n", pool.Primary(), server.URL)
+	}
+	if status := pool.Status(); status[0].Healthy || !status[1].Healthy || !status[1].Primary {
+		t.Errorf("Unexpected pool status after failover: %v\n", status)
+	}
+}
+
+func TestEndpointReadSplitting(t *testing.T) {
+	results := map[string]interface{}{"net_peerCount": "0x1", "eth_blockNumber": "0x10", "eth_syncing": false, "eth_getCode": "0x", "eth_sendTransaction": "0x1"}
+	primary := &countingNode{node: &fakeNode{results: results}}
+	replica := &countingNode{node: &fakeNode{results: results}}
+	ps, rs := httptest.NewServer(primary), httptest.NewServer(replica)
+	defer ps.Close()
+	defer rs.Close()
+
+	pool, _ := EndpointPoolFactory([]string{ps.URL, rs.URL}, true)
+	pool.Check_health(context.Background())
+	primary.count, replica.count = 0, 0
+
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_endpoints(pool)
+	sc.Call_rpc_api("eth_getCode", MultiValueParams{"0x0", "latest"})
+	sc.Call_rpc_api("eth_sendTransaction", map[string]string{})
+
+	if primary.count != 1 || replica.count != 1 {
+		t.Errorf("Expected one call to each of the primary and the replica, got %v and %v\n", primary.count, replica.count)
+	}
+}
+
+func TestEndpointDegraded(t *testing.T) {
+	// A primary that answers every call with a server error.
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	replica := httptest.NewServer(&fakeNode{results: map[string]interface{}{"eth_sendTransaction": "0x1"}})
+	defer replica.Close()
+
+	pool, _ := EndpointPoolFactory([]string{failing.URL, replica.URL}, false)
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_endpoints(pool)
+
+	// One failure degrades the primary without taking it out of rotation.
+	sc.Call_rpc_api("eth_sendTransaction", map[string]string{})
+	if status := pool.Status(); !status[0].Healthy || !status[0].Primary || status[0].Score != 50 || status[0].Failures != 1 {
+		t.Errorf("Unexpected pool status after one failure: %v\n", status)
+	}
+
+	// Repeated failures take it down.
+	for ix := 1; ix < max_endpoint_failures; ix++ {
+		sc.Call_rpc_api("eth_sendTransaction", map[string]string{})
+	}
+	if status := pool.Status(); status[0].Healthy || !status[1].Primary {
+		t.Errorf("Unexpected pool status after %v failures: %v\n", max_endpoint_failures, status)
+	}
+}
+
+func TestEndpointProbeAuth(t *testing.T) {
+	// A node that refuses requests without the bearer token.
+	node := &fakeNode{results: map[string]interface{}{"net_peerCount": "0x1", "eth_blockNumber": "0x10", "eth_syncing": false}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		node.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	pool, _ := EndpointPoolFactory([]string{server.URL}, false)
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_endpoints(pool)
+	client, err := HTTPClientFactory(&HTTPConfig{BearerToken: "secret"})
+	if err != nil {
+		t.Fatalf("HTTPClientFactory returned error: %v\n", err)
+	}
+	sc.Set_http_client(client)
+
+	pool.Check_health(context.Background())
+	if status := pool.Status(); !status[0].Healthy || status[0].Score != 100 || status[0].Block != 0x10 {
+		t.Errorf("Probe with the client's authentication returned status %v, expected a healthy endpoint at block 16\n", status)
+	}
+}
@@ -0,0 +1,455 @@
+package contract_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/open-horizon/go-solidity/utility"
+)
+
+// A client for the ethereum JSON-RPC API. It owns the connection to the node; the URL or endpoint
+// pool, the HTTP client, the retry policy and the circuit breaker, and offers typed methods for the
+// common eth_*, net_*, web3_* and personal_* calls. A client is safe for concurrent use once it is
+// configured, so one client can be shared by many SolidityContract objects with Set_client.
+type EthClient struct {
+	rpcURL      string
+	httpClient  *http.Client
+	endpoints   *EndpointPool
+	retryPolicy *RetryPolicy
+	breaker     *CircuitBreaker
+	logger      *utility.DebugTrace
+}
+
+// Create a client for the node at the given URL. An empty URL means the local node.
+func EthClientFactory(rpc_url string) *EthClient {
+	ec := new(EthClient)
+	ec.rpcURL = "http://localhost:8545"
+	if rpc_url != "" {
+		ec.rpcURL = rpc_url
+	}
+	ec.logger = utility.DebugTraceFactory(os.Getenv("mtn_soliditycontract"), "")
+	return ec
+}
+
+func (self *EthClient) Set_rpcurl(rpc string) {
+	if rpc != "" {
+		self.rpcURL = rpc
+	}
+}
+
+func (self *EthClient) Get_rpcurl() string {
+	return self.rpcURL
+}
+
+// ============================================================================
+// Structs returned by the typed methods. Quantities are returned as the hex strings the node sends,
+// exactly as they appear in the JSON-RPC API.
+//
+
+// A block, as returned by eth_getBlockByNumber and eth_getBlockByHash without full transactions.
+type Block struct {
+	Number           string   `json:"number"`
+	Hash             string   `json:"hash"`
+	ParentHash       string   `json:"parentHash"`
+	Nonce            string   `json:"nonce"`
+	Sha3Uncles       string   `json:"sha3Uncles"`
+	LogsBloom        string   `json:"logsBloom"`
+	TransactionsRoot string   `json:"transactionsRoot"`
+	StateRoot        string   `json:"stateRoot"`
+	ReceiptsRoot     string   `json:"receiptsRoot"`
+	Miner            string   `json:"miner"`
+	Difficulty       string   `json:"difficulty"`
+	TotalDifficulty  string   `json:"totalDifficulty"`
+	ExtraData        string   `json:"extraData"`
+	Size             string   `json:"size"`
+	GasLimit         string   `json:"gasLimit"`
+	GasUsed          string   `json:"gasUsed"`
+	Timestamp        string   `json:"timestamp"`
+	Transactions     []string `json:"transactions"`
+	Uncles           []string `json:"uncles"`
+}
+
+// A transaction receipt, as returned by eth_getTransactionReceipt.
+type Receipt struct {
+	TransactionHash   string `json:"transactionHash"`
+	TransactionIndex  string `json:"transactionIndex"`
+	BlockNumber       string `json:"blockNumber"`
+	BlockHash         string `json:"blockHash"`
+	CumulativeGasUsed string `json:"cumulativeGasUsed"`
+	GasUsed           string `json:"gasUsed"`
+	ContractAddress   string `json:"contractAddress"`
+	Status            string `json:"status"`
+	Logs              []Log  `json:"logs"`
+}
+
+// A log entry, as returned by eth_getLogs and the filter methods. Removed is set when the log was
+// dropped from the chain by a reorganisation.
+type Log struct {
+	LogIndex         string   `json:"logIndex"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	Address          string   `json:"address"`
+	Data             string   `json:"data"`
+	Topics           []string `json:"topics"`
+	Removed          bool     `json:"removed"`
+}
+
+// The sync state of the node. Syncing is false when the node is in sync, in which case the block
+// fields are empty.
+type SyncStatus struct {
+	Syncing       bool
+	StartingBlock string `json:"startingBlock"`
+	CurrentBlock  string `json:"currentBlock"`
+	HighestBlock  string `json:"highestBlock"`
+}
+
+// The log filter used by eth_newFilter and eth_getLogs. Each entry in Topics is either a topic
+// string, a list of alternative topic strings, or nil to match any topic in that position.
+type FilterQuery struct {
+	FromBlock string        `json:"fromBlock,omitempty"`
+	ToBlock   string        `json:"toBlock,omitempty"`
+	BlockHash string        `json:"blockHash,omitempty"`
+	Address   string        `json:"address,omitempty"`
+	Topics    []interface{} `json:"topics,omitempty"`
+}
+
+// Return a hex quantity such as a block number as a number.
+func Hex_to_uint64(hex string) uint64 {
+	if len(hex) <= 2 {
+		return 0
+	}
+	num, _ := strconv.ParseUint(hex[2:], 16, 64)
+	return num
+}
+
+// ============================================================================
+// The generic call and the typed methods built on it
+//
+
+// Invoke an RPC method on the node and unmarshal its result into result, which must be a pointer.
+// A JSON-RPC error returned by the node is returned as an RPCError.
+func (self *EthClient) Call_rpc(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	self.logger.Debug("Entry", method, params)
+	err := error(nil)
+	var jsonBytes []byte
+	var outBytes []byte
+
+	if params == nil {
+		params = []interface{}{}
+	}
+	body := map[string]interface{}{"jsonrpc": "2.0", "id": "1", "method": method, "params": params}
+
+	if jsonBytes, err = json.Marshal(body); err != nil {
+		err = &RPCError{msg: fmt.Sprintf("RPC invocation of %v failed creating JSON body %v, error: %v", method, body, err.Error())}
+	} else if outBytes, err = self.post_rpc(ctx, is_read_method(method), method, jsonBytes); err == nil {
+		rpcResp := new(rpcRawResponse)
+		if err = json.Unmarshal(outBytes, rpcResp); err != nil {
+			err = &RPCError{msg: fmt.Sprintf("RPC invocation of %v returned undecodable response %v, error: %v", method, string(outBytes), err)}
+		} else if rpcResp.Error.Message != "" {
+			err = rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of %v returned an error: %v.", method, rpcResp.Error.Message))
+		} else if result != nil && len(rpcResp.Result) != 0 {
+			if err = json.Unmarshal(rpcResp.Result, result); err != nil {
+				err = &RPCError{msg: fmt.Sprintf("RPC invocation of %v returned unexpected result %v, error: %v", method, string(rpcResp.Result), err)}
+			}
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", "")
+	return err
+}
+
+// A JSON-RPC response whose result is decoded later.
+type rpcRawResponse struct {
+	Id      interface{}     `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   rpcErrorObject  `json:"error"`
+}
+
+// Call a method that returns a hex quantity, and return it as a number.
+func (self *EthClient) call_uint64(ctx context.Context, method string, params ...interface{}) (uint64, error) {
+	res := ""
+	err := self.Call_rpc(ctx, &res, method, params...)
+	return Hex_to_uint64(res), err
+}
+
+// Call a method that returns a string.
+func (self *EthClient) call_string(ctx context.Context, method string, params ...interface{}) (string, error) {
+	res := ""
+	err := self.Call_rpc(ctx, &res, method, params...)
+	return res, err
+}
+
+func (self *EthClient) Block_number(ctx context.Context) (uint64, error) {
+	return self.call_uint64(ctx, "eth_blockNumber")
+}
+
+func (self *EthClient) Peer_count(ctx context.Context) (uint64, error) {
+	return self.call_uint64(ctx, "net_peerCount")
+}
+
+func (self *EthClient) Net_version(ctx context.Context) (string, error) {
+	return self.call_string(ctx, "net_version")
+}
+
+func (self *EthClient) Client_version(ctx context.Context) (string, error) {
+	return self.call_string(ctx, "web3_clientVersion")
+}
+
+// Return the keccak-256 hash of the hex encoded data.
+func (self *EthClient) Sha3(ctx context.Context, data string) (string, error) {
+	return self.call_string(ctx, "web3_sha3", data)
+}
+
+// eth_syncing returns false when the node is in sync and an object describing progress otherwise.
+func (self *EthClient) Syncing(ctx context.Context) (*SyncStatus, error) {
+	var raw json.RawMessage
+	status := new(SyncStatus)
+	err := self.Call_rpc(ctx, &raw, "eth_syncing")
+	if err == nil && string(raw) != "false" {
+		if err = json.Unmarshal(raw, status); err != nil {
+			err = &RPCError{msg: fmt.Sprintf("RPC invocation of eth_syncing returned unexpected result %v, error: %v", string(raw), err)}
+		}
+		status.Syncing = true
+	}
+	return status, err
+}
+
+// Return the balance in wei of the account at the given block, e.g. "latest".
+func (self *EthClient) Get_balance(ctx context.Context, account string, block string) (*big.Int, error) {
+	bal := big.NewInt(0)
+	res, err := self.call_string(ctx, "eth_getBalance", account, block)
+	if err == nil && len(res) > 2 {
+		// the math/big library doesn't like leading "0x" on hex strings
+		bal.SetString(res[2:], 16)
+	}
+	return bal, err
+}
+
+// Return the code deployed at the address at the given block, "0x" if there is none.
+func (self *EthClient) Get_code(ctx context.Context, address string, block string) (string, error) {
+	return self.call_string(ctx, "eth_getCode", address, block)
+}
+
+// Return the block with the given number or tag, or nil if the node doesn't know the block.
+func (self *EthClient) Get_block_by_number(ctx context.Context, block string) (*Block, error) {
+	var res *Block
+	err := self.Call_rpc(ctx, &res, "eth_getBlockByNumber", block, false)
+	return res, err
+}
+
+// Return the block with the given hash, or nil if the node doesn't know the block.
+func (self *EthClient) Get_block_by_hash(ctx context.Context, hash string) (*Block, error) {
+	var res *Block
+	err := self.Call_rpc(ctx, &res, "eth_getBlockByHash", hash, false)
+	return res, err
+}
+
+// Return the receipt for the transaction, or nil if the transaction hasn't been mined yet.
+func (self *EthClient) Get_transaction_receipt(ctx context.Context, tx_hash string) (*Receipt, error) {
+	var res *Receipt
+	err := self.Call_rpc(ctx, &res, "eth_getTransactionReceipt", tx_hash)
+	return res, err
+}
+
+// Send a transaction from an account managed by the node and return the transaction hash.
+func (self *EthClient) Send_transaction(ctx context.Context, tx map[string]string) (string, error) {
+	return self.call_string(ctx, "eth_sendTransaction", tx)
+}
+
+// Send a signed transaction and return the transaction hash.
+func (self *EthClient) Send_raw_transaction(ctx context.Context, signed_tx string) (string, error) {
+	return self.call_string(ctx, "eth_sendRawTransaction", signed_tx)
+}
+
+// Run a message call against the given block without creating a transaction, and return its output.
+func (self *EthClient) Eth_call(ctx context.Context, msg map[string]string, block string) (string, error) {
+	return self.call_string(ctx, "eth_call", msg, block)
+}
+
+// Sign the hex encoded data with an account managed by the node.
+func (self *EthClient) Sign(ctx context.Context, account string, data string) (string, error) {
+	return self.call_string(ctx, "eth_sign", account, data)
+}
+
+func (self *EthClient) New_filter(ctx context.Context, query *FilterQuery) (string, error) {
+	return self.call_string(ctx, "eth_newFilter", query)
+}
+
+func (self *EthClient) New_block_filter(ctx context.Context) (string, error) {
+	return self.call_string(ctx, "eth_newBlockFilter")
+}
+
+func (self *EthClient) Uninstall_filter(ctx context.Context, filter_id string) (bool, error) {
+	res := false
+	err := self.Call_rpc(ctx, &res, "eth_uninstallFilter", filter_id)
+	return res, err
+}
+
+// Return the logs that arrived since the filter was last polled.
+func (self *EthClient) Get_filter_changes(ctx context.Context, filter_id string) ([]Log, error) {
+	res := make([]Log, 0, 10)
+	err := self.Call_rpc(ctx, &res, "eth_getFilterChanges", filter_id)
+	return res, err
+}
+
+// Return every log matching the filter.
+func (self *EthClient) Get_filter_logs(ctx context.Context, filter_id string) ([]Log, error) {
+	res := make([]Log, 0, 10)
+	err := self.Call_rpc(ctx, &res, "eth_getFilterLogs", filter_id)
+	return res, err
+}
+
+// Return the logs matching the query, without installing a filter on the node.
+func (self *EthClient) Get_logs(ctx context.Context, query *FilterQuery) ([]Log, error) {
+	res := make([]Log, 0, 10)
+	err := self.Call_rpc(ctx, &res, "eth_getLogs", query)
+	return res, err
+}
+
+func (self *EthClient) Accounts(ctx context.Context) ([]string, error) {
+	res := make([]string, 0, 5)
+	err := self.Call_rpc(ctx, &res, "eth_accounts")
+	return res, err
+}
+
+// Unlock an account managed by the node for the given number of seconds, 0 meaning the node's default.
+func (self *EthClient) Personal_unlock_account(ctx context.Context, account string, passphrase string, seconds int) (bool, error) {
+	res := false
+	err := self.Call_rpc(ctx, &res, "personal_unlockAccount", account, passphrase, seconds)
+	return res, err
+}
+
+// Sign the hex encoded data with an account managed by the node, unlocking it with the passphrase.
+func (self *EthClient) Personal_sign(ctx context.Context, data string, account string, passphrase string) (string, error) {
+	return self.call_string(ctx, "personal_sign", data, account, passphrase)
+}
+
+// ============================================================================
+// The transport
+//
+
+// Send a JSON encoded request body to the node and return the raw response body. Transient failures
+// are retried according to the retry policy, and the circuit breaker fails the call fast when the
+// node has been failing.
+func (self *EthClient) post_rpc(ctx context.Context, read_only bool, method string, jsonBytes []byte) ([]byte, error) {
+	var outBytes []byte
+	err, failure := error(nil), endpoint_ok
+
+	for attempts := 1; ; attempts++ {
+		if self.breaker != nil {
+			if err = self.breaker.allow(); err != nil {
+				break
+			}
+		}
+
+		outBytes, failure, err = self.route_rpc(ctx, read_only, method, jsonBytes)
+
+		if self.breaker != nil && ctx.Err() != nil {
+			self.breaker.abandon()
+		} else if self.breaker != nil {
+			self.breaker.record(failure == endpoint_ok)
+		}
+		if failure == endpoint_ok || ctx.Err() != nil || !retryable(method, failure) || !self.retryPolicy.allows(attempts) {
+			break
+		}
+
+		wait := self.retryPolicy.backoff(attempts)
+		self.logger.Debug("Debug", fmt.Sprintf("Retrying %v in %v after attempt %v failed: %v", method, wait, attempts, err))
+		if serr := sleep_ctx(ctx, wait); serr != nil {
+			err = serr
+			break
+		}
+	}
+	return outBytes, err
+}
+
+// Send a JSON encoded request body to the node. When the contract is using an endpoint pool, the
+// request is routed to the appropriate endpoint and fails over to the next endpoint if the first one
+// fails. Requests that are not read only only fail over when they are known not to have reached the
+// failed endpoint.
+func (self *EthClient) route_rpc(ctx context.Context, read_only bool, method string, jsonBytes []byte) ([]byte, int, error) {
+	if self.endpoints == nil {
+		return self.post_rpc_url(ctx, self.rpcURL, method, jsonBytes)
+	}
+
+	var outBytes []byte
+	err, failure := error(nil), endpoint_ok
+	for _, url := range self.endpoints.candidates(read_only) {
+		if outBytes, failure, err = self.post_rpc_url(ctx, url, method, jsonBytes); failure == endpoint_ok {
+			self.endpoints.report(url, nil)
+			break
+		}
+		self.endpoints.report(url, err)
+		if ctx.Err() != nil || (!read_only && failure != endpoint_unreachable) {
+			break
+		}
+		self.logger.Debug("Debug", fmt.Sprintf("Endpoint %v failed invoking %v, trying the next endpoint.", url, method))
+	}
+	return outBytes, failure, err
+}
+
+const (
+	endpoint_ok          = iota
+	endpoint_unreachable // the request never reached the endpoint
+	endpoint_failed      // the request might have reached the endpoint, but no usable response came back
+)
+
+// Send a JSON encoded request body to a specific endpoint and return the raw response body, along
+// with an indication of whether the endpoint itself failed.
+func (self *EthClient) post_rpc_url(ctx context.Context, url string, method string, jsonBytes []byte) ([]byte, int, error) {
+	var req *http.Request
+	var resp *http.Response
+	var outBytes []byte
+	err, failure := error(nil), endpoint_ok
+
+	req, err = http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBytes))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = self.http_client().Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			if outBytes, err = ioutil.ReadAll(resp.Body); err != nil {
+				err = transport_error(fmt.Sprintf("RPC invocation of %v failed reading response message %v, error: %v", method, outBytes, err.Error()), err, true)
+				failure = endpoint_failed
+			} else if resp.StatusCode >= 400 {
+				transient := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+				err = &RPCError{msg: fmt.Sprintf("RPC http invocation of %v returned status %v: %v", method, resp.Status, string(outBytes)), Code: resp.StatusCode, Transient: transient}
+				if transient {
+					failure = endpoint_failed
+				}
+			}
+		} else if ctx.Err() != nil {
+			err, failure = transport_error(fmt.Sprintf("RPC http invocation of %v abandoned: %v", method, ctx.Err()), ctx.Err(), false), endpoint_failed
+		} else {
+			failure = endpoint_failed
+			if not_delivered(err) {
+				failure = endpoint_unreachable
+			}
+			err = transport_error(fmt.Sprintf("RPC http invocation of %v returned error: %v", method, err.Error()), err, true)
+		}
+	} else {
+		err = &RPCError{msg: fmt.Sprintf("RPC invocation of %v failed creating http request, error: %v", method, err.Error())}
+	}
+
+	return outBytes, failure, err
+}
+
+func (self *EthClient) http_client() *http.Client {
+	if self.httpClient != nil {
+		return self.httpClient
+	}
+	return default_http_client
+}
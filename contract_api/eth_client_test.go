@@ -0,0 +1,55 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEthClientTypedMethods(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{
+		"eth_blockNumber":           "0x1f",
+		"net_peerCount":             "0x3",
+		"eth_syncing":               map[string]interface{}{"startingBlock": "0x0", "currentBlock": "0x10", "highestBlock": "0x1f"},
+		"eth_getBalance":            "0xde0b6b3a7640000",
+		"eth_getTransactionReceipt": nil,
+		"eth_getFilterLogs": []interface{}{
+			map[string]interface{}{"blockNumber": "0x10", "data": "0x", "topics": []string{"0x01", "0x02"}},
+		},
+	}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	ctx := context.Background()
+	ec := EthClientFactory(server.URL)
+
+	if block, err := ec.Block_number(ctx); err != nil || block != 31 {
+		t.Errorf("Block_number returned %v, error %v, expected 31\n", block, err)
+	}
+	if peers, err := ec.Peer_count(ctx); err != nil || peers != 3 {
+		t.Errorf("Peer_count returned %v, error %v, expected 3\n", peers, err)
+	}
+	if status, err := ec.Syncing(ctx); err != nil || !status.Syncing || status.HighestBlock != "0x1f" {
+		t.Errorf("Syncing returned %v, error %v, expected syncing to 0x1f\n", status, err)
+	}
+	if bal, err := ec.Get_balance(ctx, "0x0", "latest"); err != nil || bal.String() != "1000000000000000000" {
+		t.Errorf("Get_balance returned %v, error %v, expected 1 ether\n", bal, err)
+	}
+	if receipt, err := ec.Get_transaction_receipt(ctx, "0x1234"); err != nil || receipt != nil {
+		t.Errorf("Get_transaction_receipt returned %v, error %v, expected no receipt\n", receipt, err)
+	}
+	if logs, err := ec.Get_filter_logs(ctx, "0x1"); err != nil || len(logs) != 1 || Hex_to_uint64(logs[0].BlockNumber) != 16 || logs[0].Topics[1] != "0x02" {
+		t.Errorf("Get_filter_logs returned %v, error %v\n", logs, err)
+	}
+
+	node.results["eth_syncing"] = false
+	if status, err := ec.Syncing(ctx); err != nil || status.Syncing {
+		t.Errorf("Syncing returned %v, error %v, expected not syncing\n", status, err)
+	}
+
+	var rpcErr *RPCError
+	if _, err := ec.Sign(ctx, "0x0", "0x1234"); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("Sign returned %v, expected an RPCError with code -32601\n", err)
+	}
+}
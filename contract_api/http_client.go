@@ -0,0 +1,179 @@
+package contract_api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Configuration of the HTTP client used to reach the ethereum node. The zero value produces a client
+// that honors the proxy environment variables, keeps connections alive and has no timeouts.
+type HTTPConfig struct {
+	// TLS settings. If TLSConfig is set it is used as is and the file based settings are ignored.
+	TLSConfig          *tls.Config
+	CACertFile         string // PEM file of CA certificates used to verify the node
+	ClientCertFile     string // PEM file with the client certificate, for mutual TLS
+	ClientKeyFile      string // PEM file with the client certificate's private key
+	InsecureSkipVerify bool
+
+	// Authentication and any other headers added to every request.
+	BearerToken       string
+	BasicAuthUser     string
+	BasicAuthPassword string
+	Headers           map[string]string
+
+	// The proxy to use, e.g. http://proxy.example.com:3128. When empty, the HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY environment variables are used.
+	ProxyURL string
+
+	// ConnectTimeout bounds establishing the TCP connection and the TLS handshake, ReadTimeout bounds
+	// the wait for the response headers once the request is sent, and RequestTimeout bounds the
+	// entire exchange including reading the response body.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	RequestTimeout time.Duration
+
+	// Connection reuse.
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// The client used by every EthClient that has not been given its own client. It keeps
+// connections to the node alive so that they are reused across calls.
+var default_http_client = &http.Client{
+	Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// Create an HTTP client from the configuration. The client is safe for concurrent use, so a single
+// client can be shared by many EthClient objects with Set_http_client.
+func HTTPClientFactory(cfg *HTTPConfig) (*http.Client, error) {
+	if cfg == nil {
+		cfg = new(HTTPConfig)
+	}
+
+	tlsConfig, err := cfg.tls_config()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		if proxyURL, err := url.Parse(cfg.ProxyURL); err != nil {
+			return nil, &HTTPConfigError{fmt.Sprintf("Unable to parse proxy URL %v, error: %v", cfg.ProxyURL, err)}
+		} else {
+			proxy = http.ProxyURL(proxyURL)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ReadTimeout,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+	}
+	if transport.MaxIdleConnsPerHost == 0 {
+		transport.MaxIdleConnsPerHost = 10
+	}
+	if transport.IdleConnTimeout == 0 {
+		transport.IdleConnTimeout = 90 * time.Second
+	}
+
+	var rt http.RoundTripper = transport
+	if cfg.BearerToken != "" || cfg.BasicAuthUser != "" || len(cfg.Headers) != 0 {
+		// Copy the configuration so that later changes by the caller don't leak into the client.
+		cfgCopy := *cfg
+		rt = &headerTransport{base: transport, config: &cfgCopy}
+	}
+
+	return &http.Client{Transport: rt, Timeout: cfg.RequestTimeout}, nil
+}
+
+// Build the TLS configuration from the file based settings.
+func (cfg *HTTPConfig) tls_config() (*tls.Config, error) {
+	if cfg.TLSConfig != nil {
+		return cfg.TLSConfig, nil
+	} else if cfg.CACertFile == "" && cfg.ClientCertFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CACertFile != "" {
+		if pem, err := ioutil.ReadFile(cfg.CACertFile); err != nil {
+			return nil, &HTTPConfigError{fmt.Sprintf("Unable to read CA certificate file %v, error: %v", cfg.CACertFile, err)}
+		} else {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, &HTTPConfigError{fmt.Sprintf("No certificates found in CA certificate file %v", cfg.CACertFile)}
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+
+	if cfg.ClientCertFile != "" {
+		if cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile); err != nil {
+			return nil, &HTTPConfigError{fmt.Sprintf("Unable to load client certificate %v and key %v, error: %v", cfg.ClientCertFile, cfg.ClientKeyFile, err)}
+		} else {
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// A RoundTripper that adds the configured authentication and headers to every request.
+type headerTransport struct {
+	base   http.RoundTripper
+	config *HTTPConfig
+}
+
+func (self *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request.
+	r := req.Clone(req.Context())
+	for k, v := range self.config.Headers {
+		r.Header.Set(k, v)
+	}
+	if self.config.BearerToken != "" {
+		r.Header.Set("Authorization", "Bearer "+self.config.BearerToken)
+	} else if self.config.BasicAuthUser != "" {
+		r.SetBasicAuth(self.config.BasicAuthUser, self.config.BasicAuthPassword)
+	}
+	return self.base.RoundTrip(r)
+}
+
+// Use the given HTTP client for all calls to the node. The client can be shared with other
+// EthClient objects.
+func (self *EthClient) Set_http_client(client *http.Client) {
+	self.httpClient = client
+}
+
+// Create an HTTP client from the configuration and use it for all calls to the node.
+func (self *EthClient) Set_http_config(cfg *HTTPConfig) error {
+	client, err := HTTPClientFactory(cfg)
+	if err == nil {
+		self.httpClient = client
+	}
+	return err
+}
+
+func (self *SolidityContract) Set_http_client(client *http.Client) {
+	self.client.Set_http_client(client)
+}
+
+func (self *SolidityContract) Set_http_config(cfg *HTTPConfig) error {
+	return self.client.Set_http_config(cfg)
+}
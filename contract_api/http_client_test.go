@@ -0,0 +1,60 @@
+package contract_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPConfigHeaders(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{"net_peerCount": "0x2"}}
+	var auth, custom string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, custom = r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+		node.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_rpcurl(server.URL)
+	if err := sc.Set_http_config(&HTTPConfig{BearerToken: "abc", Headers: map[string]string{"X-Api-Key": "key"}}); err != nil {
+		t.Fatalf("Set_http_config returned error: %v\n", err)
+	}
+
+	if _, err := sc.Call_rpc_api("net_peerCount", nil); err != nil {
+		t.Fatalf("Call_rpc_api returned error: %v\n", err)
+	}
+	if auth != "Bearer abc" || custom != "key" {
+		t.Errorf("Request carried Authorization %v and X-Api-Key %v, expected Bearer abc and key\n", auth, custom)
+	}
+}
+
+func TestHTTPConfigTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := HTTPClientFactory(&HTTPConfig{RequestTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("HTTPClientFactory returned error: %v\n", err)
+	}
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_rpcurl(server.URL)
+	sc.Set_http_client(client)
+
+	if _, err := sc.Call_rpc_api("net_peerCount", nil); err == nil {
+		t.Errorf("Call_rpc_api should have timed out.\n")
+	} else if _, ok := err.(*RPCError); !ok {
+		t.Errorf("Call_rpc_api returned %T, expected *RPCError\n", err)
+	}
+}
+
+func TestHTTPConfigBadCACert(t *testing.T) {
+	if _, err := HTTPClientFactory(&HTTPConfig{CACertFile: "/nonexistent/ca.pem"}); err == nil {
+		t.Errorf("HTTPClientFactory should fail with a missing CA certificate file.\n")
+	} else if _, ok := err.(*HTTPConfigError); !ok {
+		t.Errorf("HTTPClientFactory returned %T, expected *HTTPConfigError\n", err)
+	}
+}
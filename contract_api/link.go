@@ -0,0 +1,184 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The length of a link reference placeholder in hex encoded bytecode; the size of an address.
+const link_placeholder_len = 40
+
+// Return the link references left in the hex encoded bytecode, in order of first appearance.
+// Compilers before 0.5 write __Name____ placeholders, padded with underscores and holding the
+// library name, possibly prefixed by its source file. Later compilers write __$hash$__, where hash
+// is the first 34 hex digits of the keccak-256 hash of the fully qualified library name.
+func link_references(code string) []string {
+	res := make([]string, 0, 2)
+	seen := make(map[string]bool)
+	for ix := strings.Index(code, "__"); ix != -1 && ix+link_placeholder_len <= len(code); {
+		placeholder := code[ix : ix+link_placeholder_len]
+		if !seen[placeholder] {
+			seen[placeholder] = true
+			res = append(res, placeholder)
+		}
+		next := strings.Index(code[ix+link_placeholder_len:], "__")
+		if next == -1 {
+			break
+		}
+		ix = ix + link_placeholder_len + next
+	}
+	return res
+}
+
+// Return the library name in an old style placeholder, or the hash in a new style placeholder.
+func placeholder_name(placeholder string) string {
+	if strings.HasPrefix(placeholder, "__$") && strings.HasSuffix(placeholder, "$__") {
+		return placeholder[3 : link_placeholder_len-3]
+	}
+	return strings.Trim(placeholder, "_")
+}
+
+// Return the hash used in new style placeholders for a fully qualified library name.
+func placeholder_hash(fq_name string) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(fq_name))
+	return hex.EncodeToString(h.Sum(nil))[:34]
+}
+
+// Return the library address that resolves the placeholder, or the empty string. A library can be
+// given by its bare name or by its fully qualified name, source:Name. New style placeholders can
+// only be resolved by the fully qualified name, because that is what the hash is made from.
+func resolve_placeholder(placeholder string, libs map[string]string) string {
+	name := placeholder_name(placeholder)
+	for lib, addr := range libs {
+		if strings.HasPrefix(placeholder, "__$") {
+			if placeholder_hash(lib) == name {
+				return addr
+			}
+		} else if lib == name || (len(lib) > 36 && lib[:36] == name) || name == lib[strings.LastIndex(lib, ":")+1:] || lib == name[strings.LastIndex(name, ":")+1:] {
+			return addr
+		}
+	}
+	return ""
+}
+
+// Replace the link references in the bytecode with the library addresses. An error listing the
+// unresolved libraries is returned when some of the references can't be resolved.
+func link_code(code string, libs map[string]string) (string, []string) {
+	unresolved := make([]string, 0, 2)
+	for _, placeholder := range link_references(code) {
+		if addr := resolve_placeholder(placeholder, libs); addr != "" {
+			code = strings.Replace(code, placeholder, strings.ToLower(strings.TrimPrefix(addr, "0x")), -1)
+		} else {
+			unresolved = append(unresolved, placeholder_name(placeholder))
+		}
+	}
+	return code, unresolved
+}
+
+// Set the addresses of the libraries used by the contract, keyed by the library's name or fully
+// qualified name, e.g. "SafeMath" or "contracts/SafeMath.sol:SafeMath".
+func (self *SolidityContract) Set_libraries(libs map[string]string) {
+	self.libraries = make(map[string]string)
+	for lib, addr := range libs {
+		self.libraries[lib] = addr
+	}
+}
+
+func (self *SolidityContract) Get_libraries() map[string]string {
+	res := make(map[string]string)
+	for lib, addr := range self.libraries {
+		res[lib] = addr
+	}
+	return res
+}
+
+// Return the libraries referenced by the contract's bytecode that have no address yet. New style
+// references are returned as their placeholder hash.
+func (self *SolidityContract) Unresolved_libraries() []string {
+	if self.compiledContract == nil {
+		return []string{}
+	}
+	_, unresolved := link_code(self.compiledContract.Code, self.libraries)
+	return unresolved
+}
+
+// Deploy libraries that have no address before deploying the contract. Each library is loaded the
+// same way as the contract, by name, so its artifact must be found in the same place. When a
+// directory is given, libraries registered there under their name and the given version are used
+// instead of deploying them again, and newly deployed libraries are registered.
+func (self *SolidityContract) Set_deploy_libraries(deploy bool, directory *SolidityContract, version int) {
+	self.deployLibraries = deploy
+	self.libraryDirectory = directory
+	self.libraryVersion = version
+}
+
+// Return the contract's bytecode with the library addresses linked in, deploying the missing
+// libraries first if that was asked for.
+func (self *SolidityContract) linked_code(ctx context.Context) (string, error) {
+	code, unresolved := link_code(self.compiledContract.Code, self.libraries)
+	if len(unresolved) != 0 && self.deployLibraries {
+		for _, lib := range unresolved {
+			if len(lib) == 34 && is_hex(lib) {
+				// A new style placeholder only holds a hash, so there is no name to find the library by.
+				continue
+			}
+			addr, err := self.provide_library(ctx, lib[strings.LastIndex(lib, ":")+1:])
+			if err != nil {
+				return "", err
+			}
+			if self.libraries == nil {
+				self.libraries = make(map[string]string)
+			}
+			self.libraries[lib] = addr
+		}
+		code, unresolved = link_code(self.compiledContract.Code, self.libraries)
+	}
+	if len(unresolved) != 0 {
+		sort.Strings(unresolved)
+		return "", &LinkError{fmt.Sprintf("Contract %v references libraries %v that have no address, use Set_libraries() to provide them.", self.name, unresolved)}
+	}
+	return code, nil
+}
+
+// Find the library in the directory, or deploy it and register it there.
+func (self *SolidityContract) provide_library(ctx context.Context, lib string) (string, error) {
+	if self.libraryDirectory != nil {
+		if addr, err := self.libraryDirectory.Invoke_method_ctx(ctx, "get_entry_by_version", []interface{}{lib, self.libraryVersion}); err != nil {
+			return "", err
+		} else if a, ok := addr.(string); ok && a != "" && a != "0x0000000000000000000000000000000000000000" {
+			self.logger.Debug("Debug", fmt.Sprintf("Using library %v at %v from the directory.", lib, a))
+			return a, nil
+		}
+	}
+
+	lsc, err := SolidityContractFactoryWithOptions(lib, WithClient(self.client), WithBlockTracker(self.tracker), WithContractPath(self.contractPath), WithArtifactFS(self.artifactFS), WithLogger(self.logger))
+	if err != nil {
+		return "", err
+	}
+	lsc.Set_skip_eventlistener()
+	lsc.Set_libraries(self.libraries)
+	lsc.Set_deploy_libraries(true, self.libraryDirectory, self.libraryVersion)
+	if _, err = lsc.Deploy_contract_ctx(ctx, self.from, ""); err != nil {
+		return "", &LinkError{fmt.Sprintf("Unable to deploy library %v for contract %v, error: %v", lib, self.name, err)}
+	}
+	addr := lsc.Get_contract_address()
+	self.logger.Debug("Debug", fmt.Sprintf("Deployed library %v at %v.", lib, addr))
+
+	if self.libraryDirectory != nil {
+		if _, err = self.libraryDirectory.Invoke_method_ctx(ctx, "add_entry", []interface{}{lib, addr, self.libraryVersion}); err != nil {
+			return "", &LinkError{fmt.Sprintf("Unable to register library %v at %v in the directory, error: %v", lib, addr, err)}
+		}
+	}
+	return addr, nil
+}
+
+func is_hex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
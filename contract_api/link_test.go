@@ -0,0 +1,49 @@
+package contract_api
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLinkLibraries(t *testing.T) {
+	// The keccak-256 hash of the empty string, truncated to the placeholder length.
+	if h := placeholder_hash(""); h != "c5d2460186f7233c927e7db2dcc703c0e5" {
+		t.Errorf("placeholder_hash returned %v\n", h)
+	}
+
+	oldStyle := "__Math.sol:Math_________________________"
+	newStyle := "__$" + placeholder_hash("contracts/Strings.sol:Strings") + "$__"
+	code := "0x6060" + oldStyle + "6161" + newStyle + "6262" + oldStyle
+
+	refs := link_references(code)
+	if len(refs) != 2 || refs[0] != oldStyle || refs[1] != newStyle {
+		t.Fatalf("link_references returned %v\n", refs)
+	}
+
+	sc := SolidityContractFactory("uses_libraries")
+	sc.Set_compiled_contract(&ABI{Code: code})
+	if unresolved := sc.Unresolved_libraries(); len(unresolved) != 2 || unresolved[0] != "Math.sol:Math" {
+		t.Errorf("Unresolved_libraries returned %v\n", unresolved)
+	}
+
+	sc.Set_libraries(map[string]string{"Math": "0x1111111111111111111111111111111111111111"})
+	if _, err := sc.linked_code(context.Background()); err == nil {
+		t.Errorf("linked_code should have failed with an unresolved library\n")
+	} else if _, ok := err.(*LinkError); !ok {
+		t.Errorf("linked_code returned %v, expected a LinkError\n", err)
+	}
+
+	sc.Set_libraries(map[string]string{
+		"Math":                          "0x1111111111111111111111111111111111111111",
+		"contracts/Strings.sol:Strings": "0x2222222222222222222222222222222222222222",
+	})
+	linked, err := sc.linked_code(context.Background())
+	expected := "0x6060" + strings.Repeat("11", 20) + "6161" + strings.Repeat("22", 20) + "6262" + strings.Repeat("11", 20)
+	if err != nil || linked != expected {
+		t.Errorf("linked_code returned %v, error %v, expected %v\n", linked, err, expected)
+	}
+	if len(sc.Unresolved_libraries()) != 0 {
+		t.Errorf("Unresolved_libraries returned %v after linking\n", sc.Unresolved_libraries())
+	}
+}
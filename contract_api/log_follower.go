@@ -0,0 +1,237 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/open-horizon/go-solidity/utility"
+)
+
+// How many of the most recent blocks a follower remembers the logs and hashes of. A reorganisation
+// deeper than this can't be detected.
+const reorg_window = 128
+
+// Follows the logs matching a query through a filter on the node. Nodes drop filters that have not
+// been polled for a while, and all of them when they restart; when that happens the filter is
+// created again and the logs that arrived in between are fetched with eth_getLogs.
+//
+// The follower remembers the logs it delivered from the recent blocks, and the hashes of those
+// blocks, so that each log is delivered once and logs undone by a chain reorganisation are
+// retracted. A retraction is the log again with Removed set. It is sent when the node reports the
+// log as removed, or when a log shows up in a block with the same number but a different hash. With
+// a confirmation depth, logs are held back until that many blocks have been built on top of their
+// block, and a log retracted before then is never delivered.
+type log_follower struct {
+	lock      sync.Mutex
+	client    *EthClient
+	query     FilterQuery
+	filterID  string
+	block     uint64           // The newest block logs were delivered from, or the block the follower started at
+	delivered map[uint64][]Log // The logs delivered from the recent blocks
+	hashes    map[uint64]string
+	depth     uint64 // The confirmations needed before a log is delivered
+	pending   []Log  // The logs waiting for confirmations
+	logger    *utility.DebugTrace
+}
+
+// Create the filter for the query and start following it from the current block.
+func start_log_follower(ctx context.Context, client *EthClient, query FilterQuery, depth uint64, logger *utility.DebugTrace) (*log_follower, error) {
+	self := &log_follower{client: client, query: query, delivered: make(map[uint64][]Log), hashes: make(map[uint64]string), depth: depth, logger: logger}
+	err := error(nil)
+	if self.block, err = client.Block_number(ctx); err == nil {
+		self.filterID, err = client.New_filter(ctx, &self.query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return self, nil
+}
+
+// Return the logs and retractions that arrived since the last poll, recreating the filter if the
+// node has dropped it.
+func (self *log_follower) poll(ctx context.Context) ([]Log, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	logs, err := self.client.Get_filter_changes(ctx, self.filterID)
+	if errors.Is(err, ErrFilterNotFound) {
+		self.logger.Debug("Debug", fmt.Sprintf("Filter %v for %v is gone, recreating it from block %v", self.filterID, self.query.Address, self.block))
+		logs, err = self.reestablish(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return self.confirm(ctx, self.fresh(logs)), nil
+}
+
+// Pass logs fetched some other way, such as history fetched with eth_getLogs, through the
+// follower, returning the logs and retractions to deliver.
+func (self *log_follower) add(ctx context.Context, logs []Log) []Log {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.confirm(ctx, self.fresh(logs))
+}
+
+// Create a new filter and return the logs from the newest block delivered up to the current block,
+// which the new filter won't return.
+func (self *log_follower) reestablish(ctx context.Context) ([]Log, error) {
+	filterID, current, err := "", uint64(0), error(nil)
+	missed := make([]Log, 0, 10)
+
+	// The filter is created first so that no block falls between the backfill and the filter. It is
+	// only used once the backfill is done; until then the next poll tries again.
+	if filterID, err = self.client.New_filter(ctx, &self.query); err == nil {
+		if current, err = self.client.Block_number(ctx); err == nil && current >= self.block {
+			err = self.client.Get_logs_paged(ctx, &self.query, self.block, current, 0, func(logs []Log) error {
+				missed = append(missed, logs...)
+				return nil
+			})
+		}
+		if err == nil {
+			self.filterID = filterID
+		} else {
+			self.client.Uninstall_filter(ctx, filterID)
+		}
+	}
+	return missed, err
+}
+
+// Return the logs that have not been delivered yet and the retractions of delivered logs that are
+// no longer on the chain, remembering what was delivered.
+func (self *log_follower) fresh(logs []Log) []Log {
+	res := make([]Log, 0, len(logs))
+	for _, l := range logs {
+		block, key := Hex_to_uint64(l.BlockNumber), log_key(&l)
+		if l.Removed {
+			res = append(res, self.retract(block, func(d *Log) bool { return log_key(d) == key })...)
+			continue
+		}
+
+		// A block replaced without the node reporting its logs as removed takes the blocks built on
+		// it along.
+		if h := self.hashes[block]; h != "" && l.BlockHash != "" && h != l.BlockHash {
+			self.logger.Debug("Debug", fmt.Sprintf("Block %v of %v changed from %v to %v", block, self.query.Address, h, l.BlockHash))
+			for _, b := range self.recent_blocks() {
+				if b >= block {
+					res = append(res, self.retract(b, func(d *Log) bool { return true })...)
+				}
+			}
+		}
+
+		found := false
+		for _, d := range self.delivered[block] {
+			if log_key(&d) == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			self.delivered[block] = append(self.delivered[block], l)
+			self.hashes[block] = l.BlockHash
+			if block > self.block {
+				self.block = block
+			}
+			res = append(res, l)
+		}
+	}
+
+	for _, b := range self.recent_blocks() {
+		if b+reorg_window < self.block {
+			delete(self.delivered, b)
+			delete(self.hashes, b)
+		}
+	}
+	return res
+}
+
+// Forget the delivered logs of the block that match, returning their retractions, newest first.
+func (self *log_follower) retract(block uint64, match func(d *Log) bool) []Log {
+	res := make([]Log, 0, 2)
+	kept := make([]Log, 0, len(self.delivered[block]))
+	for _, d := range self.delivered[block] {
+		if match(&d) {
+			d.Removed = true
+			res = append([]Log{d}, res...)
+		} else {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == 0 {
+		delete(self.delivered, block)
+		delete(self.hashes, block)
+	} else {
+		self.delivered[block] = kept
+	}
+	return res
+}
+
+// Return the blocks logs were delivered from, newest first.
+func (self *log_follower) recent_blocks() []uint64 {
+	blocks := make([]uint64, 0, len(self.delivered))
+	for b := range self.delivered {
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] > blocks[j] })
+	return blocks
+}
+
+// Hold the logs back until they have the confirmations needed, returning those that have them. A
+// retraction of a log that is still held back cancels it out. When the current block can't be had,
+// the logs stay held back until the next call.
+func (self *log_follower) confirm(ctx context.Context, logs []Log) []Log {
+	if self.depth == 0 {
+		return logs
+	}
+
+	res := make([]Log, 0, len(logs))
+	for _, l := range logs {
+		if !l.Removed {
+			self.pending = append(self.pending, l)
+			continue
+		}
+		held := false
+		for ix := range self.pending {
+			if log_key(&self.pending[ix]) == log_key(&l) {
+				self.pending = append(self.pending[:ix], self.pending[ix+1:]...)
+				held = true
+				break
+			}
+		}
+		if !held {
+			res = append(res, l)
+		}
+	}
+
+	if len(self.pending) > 0 {
+		current, err := self.client.Block_number(ctx)
+		if err != nil {
+			self.logger.Debug("Debug", fmt.Sprintf("Holding back %v logs of %v, error: %v", len(self.pending), self.query.Address, err))
+			return res
+		}
+		kept := make([]Log, 0, len(self.pending))
+		for _, l := range self.pending {
+			if Hex_to_uint64(l.BlockNumber)+self.depth <= current {
+				res = append(res, l)
+			} else {
+				kept = append(kept, l)
+			}
+		}
+		self.pending = kept
+	}
+	return res
+}
+
+// Remove the filter from the node.
+func (self *log_follower) stop(ctx context.Context) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.client.Uninstall_filter(ctx, self.filterID)
+}
+
+// Identify a log on the chain.
+func log_key(l *Log) string {
+	return l.BlockHash + "/" + l.TransactionHash + "/" + l.LogIndex
+}
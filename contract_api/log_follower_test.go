@@ -0,0 +1,150 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLogFollowerRecreatesFilter(t *testing.T) {
+	testLog := func(block string, index string) map[string]interface{} {
+		return map[string]interface{}{"blockNumber": block, "logIndex": index, "topics": []string{testTransferTopic}}
+	}
+	a, b, c, d := testLog("0x11", "0x0"), testLog("0x12", "0x0"), testLog("0x13", "0x0"), testLog("0x14", "0x0")
+
+	// The node loses the first filter after one poll; by then it is at block 0x13.
+	current, polls := "0x10", 0
+	node := &fakeNode{results: map[string]interface{}{"eth_uninstallFilter": true}}
+	node.queue("eth_newFilter", "0x1")
+	node.queue("eth_newFilter", "0x2")
+	node.funcs = map[string]func(params []interface{}) (interface{}, error){
+		"eth_blockNumber": func(params []interface{}) (interface{}, error) {
+			return current, nil
+		},
+		"eth_getFilterChanges": func(params []interface{}) (interface{}, error) {
+			polls += 1
+			if params[0] == "0x1" && polls == 1 {
+				return []interface{}{a}, nil
+			} else if params[0] == "0x1" {
+				return nil, errors.New("filter not found")
+			}
+			return []interface{}{c, d}, nil
+		},
+		"eth_getLogs": func(params []interface{}) (interface{}, error) {
+			return []interface{}{a, b, c}, nil
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	ctx := context.Background()
+	lf, err := start_log_follower(ctx, EthClientFactory(server.URL), FilterQuery{Address: "0xb37e8570f16682474894d435b207bb9a67dec3d9"}, 0, SolidityContractFactory("some_contract").logger)
+	if err != nil {
+		t.Fatalf("start_log_follower returned error: %v\n", err)
+	}
+	blocks := func(logs []Log) []string {
+		res := make([]string, 0, len(logs))
+		for _, l := range logs {
+			res = append(res, l.BlockNumber)
+		}
+		return res
+	}
+
+	current = "0x13"
+	expected := [][]string{{"0x11"}, {"0x12", "0x13"}, {"0x14"}}
+	for ix, exp := range expected {
+		if logs, err := lf.poll(ctx); err != nil {
+			t.Fatalf("poll %v returned error: %v\n", ix, err)
+		} else if got := blocks(logs); !reflect.DeepEqual(got, exp) {
+			t.Errorf("poll %v returned logs from blocks %v, expected %v\n", ix, got, exp)
+		}
+		if ix == 1 {
+			q := node.last_params("eth_getLogs").([]interface{})[0].(map[string]interface{})
+			if q["fromBlock"] != "0x11" || q["toBlock"] != "0x13" {
+				t.Errorf("Backfill queried blocks %v to %v, expected 0x11 to 0x13\n", q["fromBlock"], q["toBlock"])
+			}
+		}
+	}
+	if lf.filterID != "0x2" {
+		t.Errorf("The follower uses filter %v, expected the new filter 0x2\n", lf.filterID)
+	}
+}
+
+func TestLogFollowerReorg(t *testing.T) {
+	testLog := func(block string, hash string, index string) Log {
+		return Log{BlockNumber: block, BlockHash: hash, TransactionHash: "0x" + hash[2:] + index[2:], LogIndex: index, Topics: []string{testTransferTopic}}
+	}
+	removed := func(l Log) Log {
+		l.Removed = true
+		return l
+	}
+	describe := func(logs []Log) []string {
+		res := make([]string, 0, len(logs))
+		for _, l := range logs {
+			s := l.BlockNumber + "/" + l.BlockHash + "/" + l.LogIndex
+			if l.Removed {
+				s = "-" + s
+			}
+			res = append(res, s)
+		}
+		return res
+	}
+
+	current := "0x10"
+	node := &fakeNode{results: map[string]interface{}{"eth_newFilter": "0x1", "eth_uninstallFilter": true}}
+	node.funcs = map[string]func(params []interface{}) (interface{}, error){
+		"eth_blockNumber": func(params []interface{}) (interface{}, error) {
+			return current, nil
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	ctx := context.Background()
+	client, logger := EthClientFactory(server.URL), SolidityContractFactory("some_contract").logger
+
+	a0, a1, b, c := testLog("0x11", "0xa1", "0x0"), testLog("0x11", "0xa1", "0x1"), testLog("0x12", "0xb1", "0x0"), testLog("0x13", "0xc1", "0x0")
+	a2, b2 := testLog("0x11", "0xa2", "0x0"), testLog("0x12", "0xb2", "0x0")
+
+	// Without confirmations, logs are delivered at once, duplicates are dropped, a log the node
+	// reports as removed is retracted, and a block that changes hash retracts it and the blocks on
+	// top of it.
+	lf, err := start_log_follower(ctx, client, FilterQuery{}, 0, logger)
+	if err != nil {
+		t.Fatalf("start_log_follower returned error: %v\n", err)
+	}
+	steps := []struct {
+		logs     []Log
+		expected []string
+	}{
+		{[]Log{a0, a1, b}, []string{"0x11/0xa1/0x0", "0x11/0xa1/0x1", "0x12/0xb1/0x0"}},
+		{[]Log{a1, b, c}, []string{"0x13/0xc1/0x0"}},
+		{[]Log{removed(c)}, []string{"-0x13/0xc1/0x0"}},
+		{[]Log{removed(c)}, []string{}},
+		{[]Log{a2, b2}, []string{"-0x12/0xb1/0x0", "-0x11/0xa1/0x1", "-0x11/0xa1/0x0", "0x11/0xa2/0x0", "0x12/0xb2/0x0"}},
+	}
+	for ix, step := range steps {
+		if got := describe(lf.add(ctx, step.logs)); !reflect.DeepEqual(got, step.expected) {
+			t.Errorf("Step %v delivered %v, expected %v\n", ix, got, step.expected)
+		}
+	}
+
+	// With confirmations, logs wait for their block to be deep enough, and a log retracted while it
+	// waits is never delivered.
+	if lf, err = start_log_follower(ctx, client, FilterQuery{}, 2, logger); err != nil {
+		t.Fatalf("start_log_follower returned error: %v\n", err)
+	}
+	current = "0x12"
+	if got := describe(lf.add(ctx, []Log{a0, b})); !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("Unconfirmed logs were delivered: %v\n", got)
+	}
+	current = "0x13"
+	if got := describe(lf.add(ctx, []Log{removed(b)})); !reflect.DeepEqual(got, []string{"0x11/0xa1/0x0"}) {
+		t.Errorf("Confirmed logs %v were delivered, expected only the log of block 0x11\n", got)
+	}
+	current = "0x20"
+	if got := describe(lf.add(ctx, nil)); !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("A log retracted before it was confirmed was delivered: %v\n", got)
+	}
+}
@@ -0,0 +1,114 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The number of blocks asked for by one eth_getLogs request, unless a query says otherwise. Most
+// public nodes accept ranges of this size.
+const default_log_page_size = 2000
+
+// A query over the past logs of a contract. The blocks from FromBlock to ToBlock are searched, both
+// included. A ToBlock of 0 means the stable block with the filter's Confirmations, see
+// Get_stable_block_number. The range is requested in pages of at most PageSize blocks, 0 meaning
+// the default.
+type LogQuery struct {
+	Filter    EventFilter
+	FromBlock uint64
+	ToBlock   uint64
+	PageSize  uint64
+}
+
+// Call the handler with each past log of the contract that matches the query's filter, in the
+// order the logs were emitted. The logs are fetched a page at a time, so a query can cover any
+// number of blocks without hitting the limits nodes put on eth_getLogs.
+func (self *SolidityContract) Query_logs(ctx context.Context, query *LogQuery, handler EventHandler) error {
+	self.logger.Debug("Entry", query)
+	err := error(nil)
+	to := query.ToBlock
+	var event *abiDefEntry
+	var topics [][]string
+
+	if self.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before querying logs.\n")}
+	} else if err = self.ensure_verified(ctx); err == nil {
+		if event, topics, err = self.filter_topics(&query.Filter); err == nil && to == 0 {
+			to, err = self.Get_stable_block_number(ctx, query.Filter.Confirmations)
+		}
+	}
+
+	if err == nil {
+		fq := FilterQuery{Address: self.contractAddress, Topics: topic_query(topics)}
+		err = self.client.Get_logs_paged(ctx, &fq, query.FromBlock, to, query.PageSize, func(logs []Log) error {
+			for ix := range logs {
+				if match_topics(topics, logs[ix].Topics) {
+					handler(self.decode_event(event, &logs[ix]))
+				}
+			}
+			return nil
+		})
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", "")
+	return err
+}
+
+// Return the number of the newest block that is the block read delay behind the current block, or
+// confirmations behind it if that is older. The current block is asked of the node.
+func (self *SolidityContract) Get_stable_block_number(ctx context.Context, confirmations uint64) (uint64, error) {
+	delay := uint64(self.blockReadDelay)
+	if confirmations > delay {
+		delay = confirmations
+	}
+	block, err := self.client.Block_number(ctx)
+	if err == nil && block > delay {
+		block -= delay
+	}
+	return block, err
+}
+
+// Call page with the logs matching the query in each page of blocks from from to to, both included,
+// in block order. A page the node refuses as too large, by the size of its range or of its result,
+// is split in half until the node accepts it; the pages then grow back to page_size. The query's
+// block fields are ignored. An error returned by page stops the query and is returned.
+func (self *EthClient) Get_logs_paged(ctx context.Context, query *FilterQuery, from uint64, to uint64, page_size uint64, page func(logs []Log) error) error {
+	if page_size == 0 {
+		page_size = default_log_page_size
+	}
+	size := page_size
+	for from <= to {
+		end := from + size - 1
+		if end > to || end < from {
+			end = to
+		}
+		q := *query
+		q.FromBlock, q.ToBlock, q.BlockHash = fmt.Sprintf("0x%x", from), fmt.Sprintf("0x%x", end), ""
+		logs, err := self.Get_logs(ctx, &q)
+		if err != nil {
+			if end > from && ctx.Err() == nil && (errors.Is(err, ErrLimitExceeded) || errors.Is(err, ErrTimeout)) {
+				self.logger.Debug("Debug", fmt.Sprintf("Splitting log query for blocks %v to %v, error: %v", from, end, err))
+				size = (end - from + 1) / 2
+				continue
+			}
+			return err
+		} else if err = page(logs); err != nil {
+			return err
+		}
+		if end == to {
+			break
+		}
+		from = end + 1
+		if size < page_size {
+			size = size * 2
+			if size > page_size {
+				size = page_size
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestQueryLogs(t *testing.T) {
+	// A node holding a transfer every 10 blocks, which refuses ranges of more than 100 blocks.
+	ranges := make([][2]uint64, 0, 20)
+	node := &fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x3e7"}}
+	node.funcs = map[string]func(params []interface{}) (interface{}, error){
+		"eth_getLogs": func(params []interface{}) (interface{}, error) {
+			q := params[0].(map[string]interface{})
+			from, to := Hex_to_uint64(q["fromBlock"].(string)), Hex_to_uint64(q["toBlock"].(string))
+			if to-from >= 100 {
+				return nil, errors.New("query returned more than 10000 results")
+			}
+			ranges = append(ranges, [2]uint64{from, to})
+			logs := make([]interface{}, 0, 10)
+			for b := (from + 9) / 10 * 10; b <= to; b += 10 {
+				logs = append(logs, map[string]interface{}{"blockNumber": "0x" + strconv.FormatUint(b, 16), "topics": []string{testTransferTopic, testTopic(testFrom), testTopic(testToA)}})
+			}
+			return logs, nil
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	sc := testEventContract(t, server.URL)
+
+	blocks := make([]uint64, 0, 100)
+	err := sc.Query_logs(context.Background(), &LogQuery{Filter: EventFilter{Event: "Transfer"}, PageSize: 400}, func(ev *Event) {
+		if ev.Name != "Transfer" || ev.Indexed["to"] != testToA {
+			t.Errorf("Query_logs delivered %v %v, expected a transfer to %v\n", ev.Name, ev.Indexed, testToA)
+		}
+		blocks = append(blocks, Hex_to_uint64(ev.Log.BlockNumber))
+	})
+	if err != nil {
+		t.Fatalf("Query_logs returned error: %v\n", err)
+	}
+	if len(blocks) != 100 {
+		t.Errorf("Query_logs delivered %v logs, expected 100\n", len(blocks))
+	}
+	for ix, b := range blocks {
+		if b != uint64(ix*10) {
+			t.Errorf("Query_logs delivered block %v as log %v, expected block %v\n", b, ix, ix*10)
+			break
+		}
+	}
+	// The pages cover every block once, in order.
+	next := uint64(0)
+	for _, r := range ranges {
+		if r[0] != next {
+			t.Errorf("Query_logs requested blocks %v to %v, expected to start at %v\n", r[0], r[1], next)
+		}
+		next = r[1] + 1
+	}
+	if next != 1000 {
+		t.Errorf("Query_logs stopped at block %v, expected 1000\n", next)
+	}
+
+	// The stable block is the read delay, or the confirmations if more, behind the current block.
+	sc.blockReadDelay = 3
+	for confirmations, expected := range map[uint64]uint64{0: 996, 10: 989} {
+		if block, err := sc.Get_stable_block_number(context.Background(), confirmations); err != nil || block != expected {
+			t.Errorf("Get_stable_block_number returned %v %v with %v confirmations, expected %v\n", block, err, confirmations, expected)
+		}
+	}
+	sc.blockReadDelay = 0
+
+	// An error from the page function stops the query.
+	stop := errors.New("stop")
+	pages := 0
+	err = sc.Get_client().Get_logs_paged(context.Background(), &FilterQuery{}, 0, 999, 50, func(logs []Log) error {
+		pages += 1
+		return stop
+	})
+	if err != stop || pages != 1 {
+		t.Errorf("Get_logs_paged returned %v after %v pages, expected to stop after the first\n", err, pages)
+	}
+
+	// A node that refuses even a single block fails the query.
+	node.funcs["eth_getLogs"] = func(params []interface{}) (interface{}, error) {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	if err = sc.Query_logs(context.Background(), &LogQuery{FromBlock: 5, ToBlock: 8}, func(ev *Event) {}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Query_logs returned %v, expected ErrLimitExceeded\n", err)
+	}
+}
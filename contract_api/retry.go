@@ -0,0 +1,164 @@
+package contract_api
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Controls how RPC calls that fail with a transient error are retried. Transient errors are those
+// where the node could not be reached or did not return a usable response; connection failures,
+// timeouts, HTTP 5xx and 429 responses. Errors returned by the node through JSON-RPC are permanent
+// and are never retried.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first. 0 or 1 disables retries, negative retries until the context is done.
+	InitialBackoff time.Duration // The wait before the first retry.
+	MaxBackoff     time.Duration // The upper bound on the wait between retries.
+	Multiplier     float64       // The factor applied to the wait after each retry.
+	Jitter         float64       // The fraction, 0 to 1, of each wait that is randomised.
+}
+
+// A reasonable policy for talking to a local node: 4 attempts over roughly 4 seconds.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 4, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second, Multiplier: 2.0, Jitter: 0.2}
+}
+
+// Return the wait before the given retry, counting from 1.
+func (self *RetryPolicy) backoff(retry int) time.Duration {
+	mult := self.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	wait := float64(self.InitialBackoff) * math.Pow(mult, float64(retry-1))
+	if self.MaxBackoff > 0 && wait > float64(self.MaxBackoff) {
+		wait = float64(self.MaxBackoff)
+	}
+	if self.Jitter > 0 {
+		jitter := math.Min(self.Jitter, 1)
+		wait = wait * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	return time.Duration(wait)
+}
+
+// Return true if another attempt is allowed after the given number of attempts.
+func (self *RetryPolicy) allows(attempts int) bool {
+	return self != nil && (self.MaxAttempts < 0 || attempts < self.MaxAttempts)
+}
+
+// Methods that change chain state. These are only retried when the failed attempt is known not to
+// have reached the node, otherwise the same transaction could be submitted twice.
+var send_methods = map[string]bool{
+	"eth_sendTransaction":      true,
+	"eth_sendRawTransaction":   true,
+	"personal_sendTransaction": true,
+}
+
+// Return true if an attempt that ended with the given endpoint failure can be retried.
+func retryable(method string, failure int) bool {
+	switch failure {
+	case endpoint_unreachable:
+		return true
+	case endpoint_failed:
+		return !send_methods[method]
+	}
+	return false
+}
+
+// The states of a circuit breaker.
+const (
+	breaker_closed    = iota // calls flow normally
+	breaker_open             // calls fail fast without reaching the node
+	breaker_half_open        // a single trial call is allowed through
+)
+
+// A circuit breaker that fails calls fast once the node has failed a number of times in a row,
+// instead of letting every caller wait for its own timeout. After the reset timeout a single trial
+// call is let through; if it succeeds the breaker closes again. A breaker can be shared by all the
+// EthClient objects talking to the same node.
+type CircuitBreaker struct {
+	lock             sync.Mutex
+	failureThreshold int
+	resetTimeout     time.Duration
+	failures         int
+	state            int
+	openedAt         time.Time
+}
+
+func CircuitBreakerFactory(failure_threshold int, reset_timeout time.Duration) *CircuitBreaker {
+	cb := new(CircuitBreaker)
+	cb.failureThreshold = failure_threshold
+	cb.resetTimeout = reset_timeout
+	cb.state = breaker_closed
+	return cb
+}
+
+// Return an error if the breaker is open and the call should not be attempted.
+func (self *CircuitBreaker) allow() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	switch self.state {
+	case breaker_open:
+		if time.Now().Sub(self.openedAt) < self.resetTimeout {
+			return &CircuitOpenError{fmt.Sprintf("RPC circuit breaker is open after %v consecutive failures, failing fast.", self.failures)}
+		}
+		self.state = breaker_half_open
+	case breaker_half_open:
+		return &CircuitOpenError{"RPC circuit breaker is waiting for a trial call to complete, failing fast."}
+	}
+	return nil
+}
+
+// Record the outcome of an attempt.
+func (self *CircuitBreaker) record(success bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if success {
+		self.failures = 0
+		self.state = breaker_closed
+		return
+	}
+	self.failures += 1
+	if self.state == breaker_half_open || self.failures >= self.failureThreshold {
+		self.state = breaker_open
+		self.openedAt = time.Now()
+	}
+}
+
+// Give up on a trial call that was abandoned by its caller, so that the next call can try again.
+func (self *CircuitBreaker) abandon() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.state == breaker_half_open {
+		self.state = breaker_open
+	}
+}
+
+// Return true if the breaker is currently failing calls fast.
+func (self *CircuitBreaker) Is_open() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.state != breaker_closed
+}
+
+// Retry RPC calls that fail with a transient error according to the policy. A nil policy disables
+// retries.
+func (self *EthClient) Set_retry_policy(policy *RetryPolicy) {
+	self.retryPolicy = policy
+}
+
+// Fail RPC calls fast when the breaker is open. A nil breaker disables the check.
+func (self *EthClient) Set_circuit_breaker(cb *CircuitBreaker) {
+	self.breaker = cb
+}
+
+func (self *SolidityContract) Set_retry_policy(policy *RetryPolicy) {
+	self.client.Set_retry_policy(policy)
+}
+
+func (self *SolidityContract) Set_circuit_breaker(cb *CircuitBreaker) {
+	self.client.Set_circuit_breaker(cb)
+}
@@ -0,0 +1,84 @@
+package contract_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// A node that fails the first few requests with an HTTP 503 before answering normally.
+type flakyNode struct {
+	node     *fakeNode
+	failures int
+	requests int
+}
+
+func (f *flakyNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests += 1
+	if f.requests <= f.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	f.node.ServeHTTP(w, r)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	node := &flakyNode{node: &fakeNode{results: map[string]interface{}{"net_peerCount": "0x1", "eth_sendTransaction": "0x1"}}, failures: 2}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_rpcurl(server.URL)
+	sc.Set_retry_policy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2.0})
+
+	if _, err := sc.Call_rpc_api("net_peerCount", nil); err != nil {
+		t.Errorf("Call_rpc_api should have succeeded on the third attempt, error: %v\n", err)
+	}
+	if node.requests != 3 {
+		t.Errorf("Call_rpc_api made %v attempts, expected 3\n", node.requests)
+	}
+
+	// A transaction that might have reached the node is never resubmitted.
+	node.requests, node.failures = 0, 1
+	if _, err := sc.Call_rpc_api("eth_sendTransaction", map[string]string{}); err == nil {
+		t.Errorf("eth_sendTransaction should not have been retried.\n")
+	}
+	if node.requests != 1 {
+		t.Errorf("eth_sendTransaction made %v attempts, expected 1\n", node.requests)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	node := &flakyNode{node: &fakeNode{results: map[string]interface{}{"net_peerCount": "0x1"}}, failures: 2}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_rpcurl(server.URL)
+	cb := CircuitBreakerFactory(2, 50*time.Millisecond)
+	sc.Set_circuit_breaker(cb)
+
+	sc.Call_rpc_api("net_peerCount", nil)
+	sc.Call_rpc_api("net_peerCount", nil)
+	if !cb.Is_open() {
+		t.Fatalf("Circuit breaker should be open after 2 failures.\n")
+	}
+
+	if _, err := sc.Call_rpc_api("net_peerCount", nil); err == nil {
+		t.Errorf("Call_rpc_api should fail fast while the breaker is open.\n")
+	} else if _, ok := err.(*CircuitOpenError); !ok {
+		t.Errorf("Call_rpc_api returned %T, expected *CircuitOpenError\n", err)
+	}
+	if node.requests != 2 {
+		t.Errorf("The open breaker let a request reach the node.\n")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := sc.Call_rpc_api("net_peerCount", nil); err != nil {
+		t.Errorf("Trial call after the reset timeout failed: %v\n", err)
+	}
+	if cb.Is_open() {
+		t.Errorf("Circuit breaker should close after a successful trial call.\n")
+	}
+}
@@ -0,0 +1,229 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// A single JSON-RPC call that is sent to the node as part of a batch. On return, Result holds the
+// raw JSON response for this call, in the same form that Call_rpc_api returns it, so that it can be
+// unmarshalled the same way. Error is set when no response could be matched to the call.
+type RPCBatchElem struct {
+	Method string
+	Params interface{}
+	Result string
+	Error  error
+}
+
+// A constant contract method invocation that is run as part of a multi-call. On return, Result
+// holds the decoded output of the method, exactly as Invoke_method would return it.
+type MethodCall struct {
+	Method string
+	Params []interface{}
+	Result interface{}
+	Error  error
+}
+
+// Send all the calls in the batch to the node in a single HTTP request. The node is free to answer
+// the calls in any order, so the responses are matched back to the calls by their id. An error is
+// returned only when the batch as a whole fails; errors for individual calls are set on the batch
+// elements.
+func (self *SolidityContract) Call_rpc_batch(batch []*RPCBatchElem) error {
+	return self.Call_rpc_batch_ctx(context.Background(), batch)
+}
+
+// Send a batch of calls, giving up when the context is cancelled or its deadline expires.
+func (self *SolidityContract) Call_rpc_batch_ctx(ctx context.Context, batch []*RPCBatchElem) error {
+	self.logger.Debug("Entry", len(batch))
+	err := error(nil)
+	var jsonBytes []byte
+	var outBytes []byte
+
+	if len(batch) == 0 {
+		self.logger.Debug("Exit ", "")
+		return nil
+	}
+
+	bodies := make([]map[string]interface{}, 0, len(batch))
+	read_only := true
+	for ix, elem := range batch {
+		elem.Result, elem.Error = "", nil
+		read_only = read_only && is_read_method(elem.Method)
+		bodies = append(bodies, self.rpc_body(ctx, elem.Method, elem.Params, strconv.Itoa(ix+1)))
+	}
+
+	self.logger.Debug("Debug", fmt.Sprintf("RPC batch JSON:%v", bodies))
+
+	if jsonBytes, err = json.Marshal(bodies); err != nil {
+		err = &RPCError{msg: fmt.Sprintf("RPC batch invocation failed creating JSON body %v, error: %v", bodies, err.Error())}
+	} else if outBytes, err = self.client.post_rpc(ctx, read_only, "batch", jsonBytes); err == nil {
+		responses := make([]json.RawMessage, 0, len(batch))
+		if err = json.Unmarshal(outBytes, &responses); err != nil {
+			err = &RPCError{msg: fmt.Sprintf("RPC batch invocation returned undecodable response %v, error: %v", string(outBytes), err)}
+		} else {
+			for _, raw := range responses {
+				var ident struct {
+					Id json.RawMessage `json:"id"`
+				}
+				if jerr := json.Unmarshal(raw, &ident); jerr != nil {
+					self.logger.Debug("Debug", fmt.Sprintf("Skipping undecodable batch response %v, error: %v", string(raw), jerr))
+					continue
+				}
+				// Some nodes return the id as a number even though it was sent as a string.
+				id, _ := strconv.Atoi(strings.Trim(string(ident.Id), "\""))
+				if id < 1 || id > len(batch) {
+					self.logger.Debug("Debug", fmt.Sprintf("Skipping batch response with unknown id %v", string(ident.Id)))
+					continue
+				}
+				batch[id-1].Result = string(raw)
+			}
+			for _, elem := range batch {
+				if elem.Result == "" {
+					elem.Error = &RPCError{msg: fmt.Sprintf("RPC batch invocation of %v did not receive a response.", elem.Method)}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", "")
+	return err
+}
+
+// Invoke many constant (read only) contract methods in a single round trip to the node. Every call
+// is evaluated against the same stable block. An error is returned only when the multi-call as a
+// whole fails; errors for individual calls are set on the calls.
+func (self *SolidityContract) Invoke_constant_methods(calls []*MethodCall) error {
+	return self.Invoke_constant_methods_ctx(context.Background(), calls)
+}
+
+// Run a multi-call, giving up when the context is cancelled or its deadline expires.
+func (self *SolidityContract) Invoke_constant_methods_ctx(ctx context.Context, calls []*MethodCall) error {
+	self.logger.Debug("Entry", len(calls))
+	err := error(nil)
+
+	if self.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before invoking any contract methods.\n")}
+	} else if self.compiledContract == nil {
+		err = &RPCError{msg: fmt.Sprintf("This object has no compiled contract. Please use Load_contract() before invoking any contract methods.\n")}
+	} else {
+		err = self.ensure_verified(ctx)
+	}
+
+	batch := make([]*RPCBatchElem, 0, len(calls))
+	pending := make([]*MethodCall, 0, len(calls))
+
+	if err == nil {
+		stable_block := self.Get_stable_block_ctx(ctx)
+		for _, call := range calls {
+			call.Result, call.Error = nil, nil
+			method_id, invocation_string := "", ""
+			if !self.is_constant(call.Method) {
+				call.Error = &FunctionNotFoundError{fmt.Sprintf("Unable to invoke %v in a multi-call because it is not a constant method.\n", call.Method)}
+			} else if method_id, call.Error = self.get_method_id(ctx, call.Method); call.Error == nil {
+				if invocation_string, call.Error = self.encodeInputString(call.Method, call.Params); call.Error == nil {
+					p := make(map[string]string)
+					p["from"] = self.from
+					p["to"] = self.contractAddress
+					p["gas"] = "0x7a120"
+					p["data"] = method_id + invocation_string
+					batch = append(batch, &RPCBatchElem{Method: "eth_call", Params: MultiValueParams{p, stable_block}})
+					pending = append(pending, call)
+				}
+			}
+		}
+		err = self.Call_rpc_batch_ctx(ctx, batch)
+	}
+
+	if err == nil {
+		for ix, elem := range batch {
+			call := pending[ix]
+			if elem.Error != nil {
+				call.Error = elem.Error
+				continue
+			}
+			rpcResp := new(rpcResponse)
+			if call.Error = json.Unmarshal([]byte(elem.Result), rpcResp); call.Error != nil {
+				continue
+			} else if rpcResp.Error.Message != "" {
+				call.Error = rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of %v failed, error: %v.", call.Method, rpcResp.Error.Message))
+			} else if res, ok := rpcResp.Result.(string); !ok || res == "0x" {
+				call.Error = &RPCError{msg: fmt.Sprintf("RPC invocation eth_call returned %v, the EVM probably failed executing method %v.", rpcResp.Result, call.Method), Kind: ErrRevert}
+			} else {
+				call.Result, call.Error = self.decodeOutputString(call.Method, res[2:])
+			}
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", "")
+	return err
+}
+
+// The state of the ethereum node as seen by a single batched status probe.
+type ethStatus struct {
+	peers   uint64
+	block   string
+	syncing bool
+	balance *big.Int
+}
+
+// Return true if the node is fully usable; connected to peers, has blocks, is done syncing and the
+// account has enough ether to run transactions.
+func (self *ethStatus) ready(ignore_peers bool) bool {
+	return (ignore_peers || self.peers > 0) && self.block != "" && self.block != "0x0" && !self.syncing && self.balance != nil && self.balance.Cmp(big.NewInt(1500000)) > 0
+}
+
+// Retrieve the peer count, the current block, the sync state and the account balance from the node
+// in a single round trip.
+func (self *SolidityContract) eth_status_probe(ctx context.Context) (*ethStatus, error) {
+	batch := []*RPCBatchElem{
+		{Method: "net_peerCount"},
+		{Method: "eth_blockNumber"},
+		{Method: "eth_syncing"},
+		{Method: "eth_getBalance", Params: MultiValueParams{self.from, "latest"}},
+	}
+
+	if err := self.Call_rpc_batch_ctx(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, len(batch))
+	for ix, elem := range batch {
+		rpcResp := new(rpcResponse)
+		if elem.Error != nil {
+			return nil, elem.Error
+		} else if err := json.Unmarshal([]byte(elem.Result), rpcResp); err != nil {
+			return nil, &RPCError{msg: fmt.Sprintf("RPC invocation of %v returned undecodable response %v, error: %v.", elem.Method, elem.Result, err)}
+		} else if rpcResp.Error.Message != "" {
+			return nil, rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of %v returned an error: %v.", elem.Method, rpcResp.Error.Message))
+		}
+		results[ix] = rpcResp.Result
+	}
+
+	status := new(ethStatus)
+	if peers, ok := results[0].(string); ok && len(peers) > 2 {
+		status.peers, _ = strconv.ParseUint(peers[2:], 16, 64)
+	}
+	if block, ok := results[1].(string); ok {
+		status.block = block
+	}
+	// eth_syncing returns false when the node is in sync, and an object describing progress otherwise.
+	if syncing, ok := results[2].(bool); !ok || syncing {
+		status.syncing = true
+	}
+	if bal, ok := results[3].(string); ok && len(bal) > 2 {
+		status.balance = big.NewInt(0)
+		// the math/big library doesn't like leading "0x" on hex strings
+		status.balance.SetString(bal[2:], 16)
+	}
+	return status, nil
+}
@@ -0,0 +1,230 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A fake ethereum node that answers JSON-RPC requests, single or batched, using a table of canned
+// results keyed by method name. Batch responses are returned in reverse order to prove that
+// responses are matched to requests by id. Results queued for a method, or for a method and its
+// first param such as "eth_getFilterChanges 0x1", are returned once each ahead of the canned
+// result. A method with a function in funcs is answered by calling it with the params. The params
+// of the last request for each method are kept.
+type fakeNode struct {
+	lock     sync.Mutex
+	results  map[string]interface{}
+	funcs    map[string]func(params []interface{}) (interface{}, error)
+	queued   map[string][]interface{}
+	params   map[string]interface{}
+	requests int
+}
+
+func (f *fakeNode) queue(method string, result interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.queued == nil {
+		f.queued = make(map[string][]interface{})
+	}
+	f.queued[method] = append(f.queued[method], result)
+}
+
+func (f *fakeNode) last_params(method string) interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.params[method]
+}
+
+func (f *fakeNode) answer(req map[string]interface{}) map[string]interface{} {
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req["id"]}
+	method := req["method"].(string)
+	if f.params == nil {
+		f.params = make(map[string]interface{})
+	}
+	f.params[method] = req["params"]
+	key := method
+	if params, ok := req["params"].([]interface{}); ok && len(params) > 0 {
+		if first, ok := params[0].(string); ok && len(f.queued[method+" "+first]) > 0 {
+			key = method + " " + first
+		}
+	}
+	if fn, ok := f.funcs[method]; ok {
+		params, _ := req["params"].([]interface{})
+		if res, err := fn(params); err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = res
+		}
+	} else if q := f.queued[key]; len(q) > 0 {
+		resp["result"], f.queued[key] = q[0], q[1:]
+	} else if res, ok := f.results[method]; ok {
+		resp["result"] = res
+	} else {
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	return resp
+}
+
+func (f *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.requests += 1
+	body, _ := ioutil.ReadAll(r.Body)
+	var out interface{}
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		reqs := make([]map[string]interface{}, 0, 10)
+		json.Unmarshal(body, &reqs)
+		resps := make([]map[string]interface{}, 0, len(reqs))
+		for ix := len(reqs) - 1; ix >= 0; ix-- {
+			resps = append(resps, f.answer(reqs[ix]))
+		}
+		out = resps
+	} else {
+		req := make(map[string]interface{})
+		json.Unmarshal(body, &req)
+		out = f.answer(req)
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestRPCBatch(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{"net_peerCount": "0x2", "eth_blockNumber": "0x10", "eth_syncing": false}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("some_contract")
+	sc.Set_rpcurl(server.URL)
+
+	batch := []*RPCBatchElem{{Method: "net_peerCount"}, {Method: "eth_blockNumber"}, {Method: "eth_syncing"}, {Method: "bogus"}}
+	if err := sc.Call_rpc_batch(batch); err != nil {
+		t.Fatalf("Call_rpc_batch returned error: %v\n", err)
+	}
+	if node.requests != 1 {
+		t.Errorf("Call_rpc_batch made %v requests, expected 1.\n", node.requests)
+	}
+
+	expected := []interface{}{"0x2", "0x10", false}
+	for ix, exp := range expected {
+		rpcResp := new(rpcResponse)
+		if batch[ix].Error != nil {
+			t.Errorf("Batch element %v returned error: %v\n", batch[ix].Method, batch[ix].Error)
+		} else if err := json.Unmarshal([]byte(batch[ix].Result), rpcResp); err != nil {
+			t.Errorf("Batch element %v returned undecodable result %v\n", batch[ix].Method, batch[ix].Result)
+		} else if rpcResp.Result != exp {
+			t.Errorf("Batch element %v returned %v, expected %v\n", batch[ix].Method, rpcResp.Result, exp)
+		}
+	}
+
+	rpcResp := new(rpcResponse)
+	if err := json.Unmarshal([]byte(batch[3].Result), rpcResp); err != nil || rpcResp.Error.Message == "" {
+		t.Errorf("Batch element bogus should have returned an RPC error, returned %v\n", batch[3].Result)
+	}
+}
+
+func TestInvokeConstantMethods(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{
+		"eth_blockNumber": "0x10",
+		"web3_sha3":       "0x1234567890abcdef",
+		"eth_call":        "0x000000000000000000000000000000000000000000000000000000000000000a",
+	}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("multi_call_contract")
+	sc.Set_rpcurl(server.URL)
+	sc.Set_skip_eventlistener()
+	if err := json.Unmarshal([]byte(testCCJSONString), &sc.compiledContract); err != nil {
+		t.Fatalf("Error Unmarshalling test JSON, error: %v\n", err)
+	}
+	sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+
+	calls := []*MethodCall{{Method: "get_container_id"}, {Method: "get_container_id"}, {Method: "kill"}}
+	if err := sc.Invoke_constant_methods(calls); err != nil {
+		t.Fatalf("Invoke_constant_methods returned error: %v\n", err)
+	}
+	for _, call := range calls[:2] {
+		if call.Error != nil || call.Result != uint64(10) {
+			t.Errorf("Multi-call of %v returned %v, expected 10. Error: %v\n", call.Method, call.Result, call.Error)
+		}
+	}
+	if _, ok := calls[2].Error.(*FunctionNotFoundError); !ok {
+		t.Errorf("Multi-call of non-constant method kill returned %v, expected FunctionNotFoundError\n", calls[2].Error)
+	}
+}
+
+func TestRPCErrorKinds(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x10", "web3_sha3": "0x1234567890abcdef"}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("error_contract")
+	sc.Set_rpcurl(server.URL)
+	sc.Set_skip_eventlistener()
+	if err := json.Unmarshal([]byte(testCCJSONString), &sc.compiledContract); err != nil {
+		t.Fatalf("Error Unmarshalling test JSON, error: %v\n", err)
+	}
+	sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+
+	calls := []*MethodCall{{Method: "get_container_id"}}
+	if err := sc.Invoke_constant_methods(calls); err != nil {
+		t.Fatalf("Invoke_constant_methods returned error: %v\n", err)
+	}
+	var rpcErr *RPCError
+	if !errors.As(calls[0].Error, &rpcErr) || rpcErr.Code != -32601 || rpcErr.Message != "method not found" {
+		t.Errorf("Multi-call returned %v, expected an RPCError with code -32601\n", calls[0].Error)
+	}
+
+	cases := map[string]error{
+		"nonce too low": ErrNonceTooLow,
+		"insufficient funds for gas * price + value": ErrInsufficientFunds,
+		"filter not found":                           ErrFilterNotFound,
+		"execution reverted":                         ErrRevert,
+		"query returned more than 10000 results":     ErrLimitExceeded,
+		"block range is too wide":                    ErrLimitExceeded,
+		"unknown account":                            nil,
+	}
+	for msg, kind := range cases {
+		obj := &rpcErrorObject{Code: -32000, Message: msg}
+		err := error(obj.wrap("RPC invocation failed, error: " + msg))
+		if kind != nil && !errors.Is(err, kind) {
+			t.Errorf("Error %v is not %v\n", msg, kind)
+		} else if kind == nil && (errors.Is(err, ErrNonceTooLow) || errors.Is(err, ErrRevert)) {
+			t.Errorf("Error %v should not have a kind\n", msg)
+		}
+	}
+}
+
+func TestCheckEthStatusCancelled(t *testing.T) {
+	// A node that is slow to answer, so that the context ends during the status check.
+	node := &fakeNode{}
+	node.funcs = map[string]func(params []interface{}) (interface{}, error){
+		"net_peerCount": func(params []interface{}) (interface{}, error) {
+			time.Sleep(300 * time.Millisecond)
+			return "0x1", nil
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("status_contract")
+	sc.Set_rpcurl(server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := sc.check_eth_status(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("check_eth_status returned %v, expected %v\n", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sc.check_eth_status(ctx); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("check_eth_status returned %v, expected %v\n", err, ErrTimeout)
+	}
+}
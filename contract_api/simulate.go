@@ -0,0 +1,47 @@
+package contract_api
+
+import (
+	"context"
+	"fmt"
+)
+
+// Run a contract method as an eth_call against the latest block, without sending a transaction,
+// and return its decoded output the way Invoke_method would for a constant method. For a method
+// that changes state this predicts what a transaction sent now would return, without paying for
+// it; the state can still change before the transaction is mined.
+func (self *SolidityContract) Simulate_method(ctx context.Context, method_name string, params []interface{}) (interface{}, error) {
+	self.logger.Debug("Entry", method_name, params)
+	method_id, invocation_string, out, err := "", "", "", error(nil)
+	var result interface{}
+
+	if self.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before invoking any contract methods.\n")}
+	} else if self.compiledContract == nil {
+		err = &RPCError{msg: fmt.Sprintf("This object has no compiled contract. Please use Load_contract() before invoking any contract methods.\n")}
+	} else if err = self.ensure_verified(ctx); err == nil {
+		if method_id, err = self.get_method_id(ctx, method_name); err == nil {
+			invocation_string, err = self.encodeInputString(method_name, params)
+		}
+	}
+
+	if err == nil {
+		p := make(map[string]string)
+		p["from"] = self.from
+		p["to"] = self.contractAddress
+		p["gas"] = "0x7a120"
+		p["data"] = method_id + invocation_string
+		if out, err = self.client.Eth_call(ctx, p, "latest"); err == nil {
+			if len(out) <= 2 {
+				err = &RPCError{msg: fmt.Sprintf("RPC invocation eth_call returned %v, the EVM probably failed executing method %v.", out, method_name), Kind: ErrRevert}
+			} else {
+				result, err = self.decodeOutputString(method_name, out[2:])
+			}
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", result)
+	return result, err
+}
@@ -0,0 +1,40 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSimulateMethod(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{
+		"eth_blockNumber": "0x10",
+		"web3_sha3":       "0x1234567890abcdef",
+		"eth_call":        "0x0000000000000000000000000000000000000000000000000000000000000001",
+	}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc := SolidityContractFactory("simulate_contract")
+	sc.Set_rpcurl(server.URL)
+	sc.Set_skip_eventlistener()
+	if err := json.Unmarshal([]byte(testCCJSONString), &sc.compiledContract); err != nil {
+		t.Fatalf("Error Unmarshalling test JSON, error: %v\n", err)
+	}
+	sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+
+	// A non-constant method is run as an eth_call, not sent as a transaction.
+	if res, err := sc.Simulate_method(context.Background(), "exec_complete", nil); err != nil || res != true {
+		t.Errorf("Simulate_method returned %v, expected true. Error: %v\n", res, err)
+	}
+	if params := node.last_params("eth_call").([]interface{}); params[1] != "latest" {
+		t.Errorf("Simulate_method ran against block %v, expected latest\n", params[1])
+	}
+
+	node.queue("eth_call", "0x")
+	if _, err := sc.Simulate_method(context.Background(), "exec_complete", nil); !errors.Is(err, ErrRevert) {
+		t.Errorf("Simulate_method returned %v, expected %v\n", err, ErrRevert)
+	}
+}
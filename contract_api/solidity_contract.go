@@ -1,14 +1,14 @@
 package contract_api
 
 import (
-	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"math/big"
-	"net/http"
-	"os"
+	"net"
 	"reflect"
 	"github.com/open-horizon/go-solidity/utility"
 	"strconv"
@@ -21,10 +21,9 @@ type SolidityContract struct {
 	baseBody              map[string]string
 	name                  string
 	from                  string
-	rpcURL                string
 	compiledContract      *ABI
 	contractAddress       string
-	filter_id             string
+	listener              *log_follower
 	noEventlistener       bool
 	tx_delay_toleration   int
 	sync_delay_toleration int
@@ -32,44 +31,29 @@ type SolidityContract struct {
 	logger                *utility.DebugTrace
 	logBlockchainStats    string
 	missingReceiptRetry   int
+	noRecentBlocks        int
+	blockReadDelay        int
+	blockUpdateDelay      int
+	contractPath          string
+	libraries             map[string]string
+	deployLibraries       bool
+	libraryDirectory      *SolidityContract
+	libraryVersion        int
+	verifyCode            bool
+	compiler              *Compiler
+	source                string
+	codeVerified          bool
+	contractKey           string
+	artifactFile          string
+	artifactFS            fs.FS
+	client                *EthClient
+	tracker               *BlockTracker
+	pollInterval          time.Duration
+	subscriptions         map[*Subscription]bool
+	subscriptionsLock     sync.Mutex
 }
 
 
-// === global state used to detect when we havent seen a block in a while ===
-type blockSync struct {
-	lastBlockTime  int64	// The unix time in seconds when blockNumber was last updated
-	blockNumber    string 	// The last block that was seen
-	blockStable    string   // The last block taht can be read from
-}
-
-var global_block_state_lock sync.Mutex
-var global_block_state blockSync
-var no_recent_blocks int
-var block_read_delay int
-var block_update_delay int
-
-func update_block(blockNumber string) {
-	global_block_state_lock.Lock()
-	defer global_block_state_lock.Unlock()
-
-	if global_block_state.blockNumber == "" || blockNumber != global_block_state.blockNumber {
-		global_block_state.lastBlockTime = time.Now().Unix()
-		global_block_state.blockNumber = blockNumber
-		block,_ := strconv.ParseUint(blockNumber[2:], 16, 32)
-		block = block - uint64(block_read_delay)
-		global_block_state.blockStable = fmt.Sprintf("0x%x", block)
-	}
-}
-
-func blocks_stopped() bool {
-	last_block_time := global_block_state.lastBlockTime
-	delta := time.Now().Unix()-last_block_time
-	if int(delta) >= no_recent_blocks {
-		return true
-	}
-	return false
-}
-
 var global_sigcache = make(map[string]string)
 var global_sigcache_lock sync.Mutex
 
@@ -86,63 +70,24 @@ func cache_method_hash(method string, hash string) {
 	global_sigcache[method] = hash
 }
 func SolidityContractFactory(name string) *SolidityContract {
-	sc := new(SolidityContract)
-	sc.name = name
-	sc.rpcURL = "http://localhost:8545"
-	sc.compiledContract = nil
-	sc.baseBody = make(map[string]string)
-	sc.baseBody["jsonrpc"] = "2.0"
-	sc.baseBody["id"] = "1"
-	sc.logger = utility.DebugTraceFactory(os.Getenv("mtn_soliditycontract"), "")
-	var delay int
-	var err error
-	if delay, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_txdelay")); err != nil || delay == 0 {
-		delay = 180
-	}
-	sc.tx_delay_toleration = delay
-	var sync_delay int
-	if sync_delay, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_syncdelay")); err != nil || sync_delay == 0 {
-		sync_delay = 180
-	}
-	sc.sync_delay_toleration = sync_delay
-	var integration_test int
-	if integration_test, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_integration")); err != nil || integration_test == 0 {
-		integration_test = 0
-	}
-	sc.integration_test = integration_test
-	
-	if no_recent_blocks, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_no_recent_blocks")); err != nil || no_recent_blocks == 0 {
-		no_recent_blocks = 300
-	}
-
-	sc.logBlockchainStats = os.Getenv("mtn_soliditycontract_logstats")
-
-	if block_read_delay, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_block_read_delay")); err != nil {
-		block_read_delay = 0
-	}
-
-	if block_update_delay, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_block_update_delay")); err != nil {
-		block_update_delay = 10
-	}
-
-	var receipt int
-	if receipt, err = strconv.Atoi(os.Getenv("mtn_soliditycontract_missing_receipt_retry")); err != nil || receipt == 0 {
-		receipt = 1
-	}
-	sc.missingReceiptRetry = receipt
-
+	// The configuration only comes from the environment, and invalid values there fall back to the
+	// defaults, so this can't fail.
+	sc, _ := SolidityContractFactoryWithOptions(name)
 	return sc
 }
 
 func (self *SolidityContract) Get_stable_block() string {
-	delta := time.Now().Unix()-global_block_state.lastBlockTime
-	if int(delta) >= block_update_delay {
-		if _, err := self.get_current_block(); err != nil {
+	return self.Get_stable_block_ctx(context.Background())
+}
+
+func (self *SolidityContract) Get_stable_block_ctx(ctx context.Context) string {
+	if self.Get_block_tracker().age() >= self.blockUpdateDelay {
+		if _, err := self.get_current_block(ctx); err != nil {
 			self.logger.Debug("Debug", err)
 		}
 	}
 
-	return global_block_state.blockStable
+	return self.Get_block_tracker().stable(self.blockReadDelay)
 }
 
 
@@ -154,10 +99,15 @@ func (self *SolidityContract) Get_sig_cache_as_string() string {
 }
 
 func (self *SolidityContract) dump_block_info() {
-	self.logger.Debug("Debug", fmt.Sprintf("Current block %v, stable block %v", global_block_state.blockNumber, global_block_state.blockStable))
+	self.logger.Debug("Debug", fmt.Sprintf("Current block %v, stable block %v", self.Get_block_tracker().Current(), self.Get_block_tracker().stable(self.blockReadDelay)))
 }
 
 func (self *SolidityContract) Deploy_contract(from string, block_chain_url string) (bool, error) {
+	return self.Deploy_contract_ctx(context.Background(), from, block_chain_url)
+}
+
+// Deploy the contract, giving up when the context is cancelled or its deadline expires.
+func (self *SolidityContract) Deploy_contract_ctx(ctx context.Context, from string, block_chain_url string) (bool, error) {
 	self.logger.Debug("Entry", from, block_chain_url)
 	result, tx_address, err := false, "", error(nil)
 
@@ -166,16 +116,17 @@ func (self *SolidityContract) Deploy_contract(from string, block_chain_url strin
 	} else {
 		self.from = from
 		if block_chain_url != "" {
-			self.rpcURL = block_chain_url
+			self.client.Set_rpcurl(block_chain_url)
 		}
 		if self.compiledContract == nil {
-			self.compiledContract, err = self.compile_contract()
+			self.compiledContract, err = self.compile_contract(ctx)
 		}
 
 		if err == nil {
-			if tx_address, err = self.create_contract(); err == nil {
-				if self.contractAddress, err = self.get_contract(tx_address); err == nil {
-					if self.filter_id, err = self.establish_event_listener(); err == nil {
+			if tx_address, err = self.create_contract(ctx); err == nil {
+				if self.contractAddress, err = self.get_contract(ctx, tx_address); err == nil {
+					self.codeVerified = true
+					if err = self.establish_event_listener(ctx); err == nil {
 						result = true
 					}
 				}
@@ -192,6 +143,12 @@ func (self *SolidityContract) Deploy_contract(from string, block_chain_url strin
 }
 
 func (self *SolidityContract) Load_contract(from string, block_chain_url string) (bool, error) {
+	return self.Load_contract_ctx(context.Background(), from, block_chain_url)
+}
+
+// Load the contract, giving up when the context is cancelled or its deadline expires. With a
+// compiler set the sources may be compiled, which the context also bounds.
+func (self *SolidityContract) Load_contract_ctx(ctx context.Context, from string, block_chain_url string) (bool, error) {
 	self.logger.Debug("Entry", from, block_chain_url)
 	result, err := false, error(nil)
 
@@ -200,10 +157,10 @@ func (self *SolidityContract) Load_contract(from string, block_chain_url string)
 	} else {
 		self.from = from
 		if block_chain_url != "" {
-			self.rpcURL = block_chain_url
+			self.client.Set_rpcurl(block_chain_url)
 		}
 		if self.compiledContract == nil {
-			if self.compiledContract, err = self.compile_contract(); err == nil {
+			if self.compiledContract, err = self.compile_contract(ctx); err == nil {
 				result = true
 			}
 		} else {
@@ -219,38 +176,29 @@ func (self *SolidityContract) Load_contract(from string, block_chain_url string)
 }
 
 func (self *SolidityContract) Invoke_method(method_name string, params []interface{}) (interface{}, error) {
+	return self.Invoke_method_ctx(context.Background(), method_name, params)
+}
+
+// Invoke a contract method, giving up when the context is cancelled or its deadline expires. The
+// context also bounds the wait for the transaction receipt of a non-constant method.
+func (self *SolidityContract) Invoke_method_ctx(ctx context.Context, method_name string, params []interface{}) (interface{}, error) {
 	self.logger.Debug("Entry", method_name, params)
-	out, method_id, invocation_string, eth_method, hex_sig, found, err := "", "", "", "", "", false, error(nil)
+	out, method_id, invocation_string, eth_method, found, err := "", "", "", "", false, error(nil)
 	var result interface{}
 	var rpcResp *rpcResponse = new(rpcResponse)
 
 	if (self.contractAddress == "") {
-		err = &RPCError{fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before invoking any contract methods.\n")}
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before invoking any contract methods.\n")}
 	} else if (self.compiledContract == nil ) {
-		err = &RPCError{fmt.Sprintf("This object has no compiled contract. Please use Load_contract() before invoking any contract methods.\n")}
+		err = &RPCError{msg: fmt.Sprintf("This object has no compiled contract. Please use Load_contract() before invoking any contract methods.\n")}
+	} else {
+		err = self.ensure_verified(ctx)
 	}
 
-	self.logger.Debug("Debug", fmt.Sprintf("Current stable block: %v", self.Get_stable_block()))
-	self.logger.Debug("Debug", fmt.Sprintf("Current sig cache: %v", self.Get_sig_cache_as_string()))
-
 	if err == nil {
-		// Get hashed signature from the cache
-		method_id = get_cached_method_hash(self.name + "." + method_name)
-		if method_id == "" {
-			// Create a new signature hash and cache it
-			if hex_sig, err = self.get_method_sig(method_name); err == nil {
-				if out, err = self.Call_rpc_api("web3_sha3", hex_sig); err == nil {
-					if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-						if rpcResp.Error.Message != "" {
-							err = &RPCError{fmt.Sprintf("RPC hash of method signature for %v failed, error: %v.", method_name, rpcResp.Error.Message)}
-						} else {
-							method_id = rpcResp.Result.(string)[:10]
-							cache_method_hash(self.name + "." + method_name, method_id)
-						}
-					}
-				}
-			}
-		}
+		self.logger.Debug("Debug", fmt.Sprintf("Current stable block: %v", self.Get_stable_block_ctx(ctx)))
+		self.logger.Debug("Debug", fmt.Sprintf("Current sig cache: %v", self.Get_sig_cache_as_string()))
+		method_id, err = self.get_method_id(ctx, method_name)
 	}
 
 	if err == nil {
@@ -265,7 +213,7 @@ func (self *SolidityContract) Invoke_method(method_name string, params []interfa
 
 	// Let's make sure our ethereum instance is still working correctly
 	if eth_method == "eth_sendTransaction" {
-		err = self.check_eth_status()
+		err = self.check_eth_status(ctx)
 	}
 
 	if err == nil {
@@ -279,10 +227,10 @@ func (self *SolidityContract) Invoke_method(method_name string, params []interfa
 		retryCount := self.missingReceiptRetry + 1
 		for retryCount != 0 && err == nil {
 
-			if out, err = self.Call_rpc_api(eth_method, p); err == nil {
+			if out, err = self.Call_rpc_api_ctx(ctx, eth_method, p); err == nil {
 				if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
 					if rpcResp.Error.Message != "" {
-						err = &RPCError{fmt.Sprintf("RPC invocation of %v failed, error: %v.", method_name, rpcResp.Error.Message)}
+						err = rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of %v failed, error: %v.", method_name, rpcResp.Error.Message))
 					} else {
 						if !self.is_constant(method_name) {
 							tx_address := rpcResp.Result.(string)
@@ -290,27 +238,28 @@ func (self *SolidityContract) Invoke_method(method_name string, params []interfa
 
 							start_timer := time.Now()
 							for !found && err == nil {
-								if out, err = self.Call_rpc_api("eth_getTransactionReceipt", tx_address); err == nil {
+								if out, err = self.Call_rpc_api_ctx(ctx, "eth_getTransactionReceipt", tx_address); err == nil {
 									if err = json.Unmarshal([]byte(out), rpcTResp); err == nil {
 										if rpcTResp.Error.Message != "" {
-											err = &RPCError{fmt.Sprintf("RPC transaction receipt for tx %v, invoking %v returned an error: %v.", tx_address, method_name, rpcResp.Error.Message)}
+											err = rpcTResp.Error.wrap(fmt.Sprintf("RPC transaction receipt for tx %v, invoking %v returned an error: %v.", tx_address, method_name, rpcTResp.Error.Message))
 										} else {
 											//self.logger.Debug("Debug",rpcTResp.Result)
 											if rpcTResp.Result.BlockNumber != "" {
 												result = 0
 												found = true
 												retryCount = 0
-												update_block(rpcTResp.Result.BlockNumber)
-												self.log_stats(rpcTResp)
+												self.Get_block_tracker().update(rpcTResp.Result.BlockNumber)
+												self.log_stats(ctx, rpcTResp)
 											} else {
 												delta := time.Now().Sub(start_timer).Seconds()
 												if int(delta) < self.tx_delay_toleration {
 													self.logger.Debug("Debug", fmt.Sprintf("Waiting for transaction %v to run for %v seconds.", tx_address, delta))
-													time.Sleep(5000 * time.Millisecond)
-													err = self.check_eth_status()
+													if err = sleep_ctx(ctx, 5000 * time.Millisecond); err == nil {
+														err = self.check_eth_status(ctx)
+													}
 												} else {
 													if retryCount == 1 {
-														err = &RPCError{fmt.Sprintf("RPC transaction receipt timed out for tx %v, invoking %v after %v seconds and %v retries.", tx_address, method_name, delta, self.missingReceiptRetry)}
+														err = &RPCError{msg: fmt.Sprintf("RPC transaction receipt timed out for tx %v, invoking %v after %v seconds and %v retries.", tx_address, method_name, delta, self.missingReceiptRetry), Kind: ErrTimeout}
 													} else {
 														retryCount = retryCount - 1
 														self.logger.Debug("Debug", fmt.Sprintf("Retrying transaction submission to %v for method %v seconds.", tx_address, method_name))
@@ -327,7 +276,7 @@ func (self *SolidityContract) Invoke_method(method_name string, params []interfa
 							if rpcResp.Result != "0x" {
 								result, err = self.decodeOutputString(method_name, rpcResp.Result.(string)[2:])
 							} else {
-								err = &RPCError{fmt.Sprintf("RPC invocation eth_call returned %v, the EVM probably failed executing method %v.", rpcResp.Result, method_name)}
+								err = &RPCError{msg: fmt.Sprintf("RPC invocation eth_call returned %v, the EVM probably failed executing method %v.", rpcResp.Result, method_name), Kind: ErrRevert}
 							}
 						}
 					}
@@ -345,45 +294,51 @@ func (self *SolidityContract) Invoke_method(method_name string, params []interfa
 }
 
 func (self *SolidityContract) Wait_for_event(event_code []uint64, related_contract string) ([]uint64, error) {
+	return self.Wait_for_event_ctx(context.Background(), event_code, related_contract)
+}
+
+// Wait for one of the events, giving up when the context is cancelled or its deadline expires. If
+// the node drops the event filter, for example because it restarted, the filter is created again
+// and the events emitted in the meantime are not lost. The event code and the related contract are
+// read from the first two indexed topics, the way the contracts of this project emit them; use
+// Wait_for_event_matching to wait for any other event.
+func (self *SolidityContract) Wait_for_event_ctx(ctx context.Context, event_code []uint64, related_contract string) ([]uint64, error) {
 	self.logger.Debug("Entry", "")
-	out, found, err := "", false, error(nil)
-	var rpcResp *rpcGetFilterChangesResponse = new(rpcGetFilterChangesResponse)
+	found, err := false, error(nil)
+	var logs []Log
 	var ev_code uint64
 	var ret_ev_code []uint64
 	ret_ev_code = make([]uint64, 0, 10)
 
-	for !found && err == nil {
-		if out, err = self.Call_rpc_api("eth_getFilterChanges", self.filter_id); err == nil {
-			if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-				if rpcResp.Error.Message != "" {
-					err = &RPCError{fmt.Sprintf("RPC receive events for %v failed, error: %v.", self.contractAddress, rpcResp.Error.Message)}
-				} else {
-					//self.logger.Debug("Debug",rpcResp.Result)
-					if len(rpcResp.Result) > 0 {
-						for _, ev := range rpcResp.Result {
-							if len(ev.Topics) > 2 {
-								if ev_code, err = strconv.ParseUint(ev.Topics[1][2:], 16, 32); err != nil {
-									err = &RPCError{fmt.Sprintf("RPC event code not parse-able %v, error: %v.", ev.Topics[1], err)}
-									break
-								} else {
-									for _, requested_evc := range event_code {
-										if ev_code == requested_evc && ev.Topics[2][26:] == related_contract[2:] {
-											found = true
-											ret_ev_code = append(ret_ev_code, ev_code)
-											break
-										}
-									}
+	if self.listener == nil {
+		err = &RPCError{msg: fmt.Sprintf("This object has no event listener. Please use Set_contract_address() before waiting for events.\n")}
+	} else {
+		err = self.ensure_verified(ctx)
+	}
 
-								}
+	for !found && err == nil {
+		if logs, err = self.listener.poll(ctx); err == nil {
+			for _, ev := range logs {
+				if len(ev.Topics) > 2 && !ev.Removed {
+					if ev_code, err = strconv.ParseUint(ev.Topics[1][2:], 16, 32); err != nil {
+						err = &RPCError{msg: fmt.Sprintf("RPC event code not parse-able %v, error: %v.", ev.Topics[1], err)}
+						break
+					} else {
+						for _, requested_evc := range event_code {
+							if ev_code == requested_evc && ev.Topics[2][26:] == related_contract[2:] {
+								found = true
+								ret_ev_code = append(ret_ev_code, ev_code)
+								break
 							}
 						}
-					}
-					if !found {
-						self.logger.Debug("Debug", fmt.Sprintf("Waiting for events on contract %v.", self.contractAddress))
-						time.Sleep(5000 * time.Millisecond)
+
 					}
 				}
 			}
+			if !found && err == nil {
+				self.logger.Debug("Debug", fmt.Sprintf("Waiting for events on contract %v.", self.contractAddress))
+				err = sleep_ctx(ctx, 5000 * time.Millisecond)
+			}
 		}
 	}
 
@@ -398,9 +353,29 @@ func (self *SolidityContract) Get_contract_address() string {
 	return self.contractAddress
 }
 
+// Set the contract address and establish the event listener. When code verification is on, the
+// check is left to the first call that reads from the contract, because there is no way to report
+// it from here.
 func (self *SolidityContract) Set_contract_address(addr string) {
 	self.contractAddress = addr
-	self.filter_id, _ = self.establish_event_listener()
+	self.codeVerified = false
+	self.establish_event_listener(context.Background())
+}
+
+// Set the contract address and establish the event listener, giving up when the context is
+// cancelled or its deadline expires. When code verification is on, an address that doesn't hold
+// this contract is refused.
+func (self *SolidityContract) Set_contract_address_ctx(ctx context.Context, addr string) error {
+	err := error(nil)
+	self.contractAddress = addr
+	self.codeVerified = false
+	if self.verifyCode {
+		if err = self.Verify_code(ctx); err != nil {
+			self.contractAddress = ""
+			return err
+		}
+	}
+	return self.establish_event_listener(ctx)
 }
 
 func (self *SolidityContract) Get_compiled_contract() *ABI {
@@ -415,10 +390,22 @@ func (self *SolidityContract) Set_from(f string) {
 	self.from = f
 }
 
+func (self *SolidityContract) Get_from() string {
+	return self.from
+}
+
 func (self *SolidityContract) Set_rpcurl(rpc string) {
-	if rpc != "" {
-		self.rpcURL = rpc
-	}
+	self.client.Set_rpcurl(rpc)
+}
+
+// Use the given client for all calls to the node. A client can be shared by many contracts, so that
+// they share one connection pool, retry policy and circuit breaker.
+func (self *SolidityContract) Set_client(client *EthClient) {
+	self.client = client
+}
+
+func (self *SolidityContract) Get_client() *EthClient {
+	return self.client
 }
 
 func (self *SolidityContract) Set_skip_eventlistener() {
@@ -428,7 +415,8 @@ func (self *SolidityContract) Set_skip_eventlistener() {
 func (self *SolidityContract) is_constant(method_name string) bool {
 	function := self.getFunctionFromABI(method_name)
 	if function != nil {
-		return function.Constant
+		// Newer compilers drop constant in favour of stateMutability.
+		return function.Constant || function.StateMutability == "view" || function.StateMutability == "pure"
 	} else {
 		return false
 	}
@@ -452,24 +440,54 @@ func (self *SolidityContract) get_method_sig(method_name string) (string, error)
 	return result, err
 }
 
-func (self *SolidityContract) create_contract() (string, error) {
+// Return the 4 byte method id used to invoke a method, computing the hash of the method signature
+// and caching it if it has not been seen before.
+func (self *SolidityContract) get_method_id(ctx context.Context, method_name string) (string, error) {
+	out, method_id, hex_sig, err := "", "", "", error(nil)
+	var rpcResp *rpcResponse = new(rpcResponse)
+
+	// Get hashed signature from the cache
+	method_id = get_cached_method_hash(self.name + "." + method_name)
+	if method_id == "" {
+		// Create a new signature hash and cache it
+		if hex_sig, err = self.get_method_sig(method_name); err == nil {
+			if out, err = self.Call_rpc_api_ctx(ctx, "web3_sha3", hex_sig); err == nil {
+				if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
+					if rpcResp.Error.Message != "" {
+						err = rpcResp.Error.wrap(fmt.Sprintf("RPC hash of method signature for %v failed, error: %v.", method_name, rpcResp.Error.Message))
+					} else {
+						method_id = rpcResp.Result.(string)[:10]
+						cache_method_hash(self.name + "." + method_name, method_id)
+					}
+				}
+			}
+		}
+	}
+	return method_id, err
+}
+
+func (self *SolidityContract) create_contract(ctx context.Context) (string, error) {
 	self.logger.Debug("Entry", "")
 	result, out, err := "", "", error(nil)
 	var rpcResp *rpcResponse = new(rpcResponse)
 
-	if err = self.check_eth_status(); err == nil {
+	if err = self.check_eth_status(ctx); err == nil {
 
-		params := make(map[string]string)
-		params["from"] = self.from
-		params["gas"] = "0x16e360"
-		params["data"] = self.compiledContract.Code
+		// Unresolved library references would be deployed as broken bytecode.
+		code := ""
+		if code, err = self.linked_code(ctx); err == nil {
+			params := make(map[string]string)
+			params["from"] = self.from
+			params["gas"] = "0x16e360"
+			params["data"] = code
 
-		if out, err = self.Call_rpc_api("eth_sendTransaction", params); err == nil {
-			if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-				if rpcResp.Error.Message != "" {
-					err = &RPCError{fmt.Sprintf("RPC contract deploy of %v returned an error: %v.", self.name, rpcResp.Error.Message)}
-				} else {
-					result = rpcResp.Result.(string)
+			if out, err = self.Call_rpc_api_ctx(ctx, "eth_sendTransaction", params); err == nil {
+				if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
+					if rpcResp.Error.Message != "" {
+						err = rpcResp.Error.wrap(fmt.Sprintf("RPC contract deploy of %v returned an error: %v.", self.name, rpcResp.Error.Message))
+					} else {
+						result = rpcResp.Result.(string)
+					}
 				}
 			}
 		}
@@ -482,50 +500,53 @@ func (self *SolidityContract) create_contract() (string, error) {
 	return result, err
 }
 
-func (self *SolidityContract) get_contract(tx_address string) (string, error) {
+func (self *SolidityContract) get_contract(ctx context.Context, tx_address string) (string, error) {
 	self.logger.Debug("Entry", tx_address)
 	result, out, found, err := "", "", false, error(nil)
 	var rpcResp *rpcGetTransactionResponse = new(rpcGetTransactionResponse)
 
 	start_timer := time.Now()
 	for !found && err == nil {
-		if out, err = self.Call_rpc_api("eth_getTransactionReceipt", tx_address); err == nil {
+		if out, err = self.Call_rpc_api_ctx(ctx, "eth_getTransactionReceipt", tx_address); err == nil {
 			if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
 				if rpcResp.Error.Message != "" {
-					err = &RPCError{fmt.Sprintf("RPC transaction receipt for deploy of %v returned an error: %v.", self.name, rpcResp.Error.Message)}
+					err = rpcResp.Error.wrap(fmt.Sprintf("RPC transaction receipt for deploy of %v returned an error: %v.", self.name, rpcResp.Error.Message))
 				} else {
 					//self.logger.Debug("Debug",rpcResp.Result.ContractAddress)
 					if rpcResp.Result.ContractAddress != "" {
 						result = rpcResp.Result.ContractAddress
 						found = true
-						update_block(rpcResp.Result.BlockNumber)
-						self.log_stats(rpcResp)
+						self.Get_block_tracker().update(rpcResp.Result.BlockNumber)
+						self.log_stats(ctx, rpcResp)
 						// Dont return until the block with the contract in it becomes the current stable block
 						block_timer := time.Now()
 						target_block,_ := strconv.ParseUint(rpcResp.Result.BlockNumber[2:], 16, 32)
 						for err == nil {
-							time.Sleep(5000 * time.Millisecond)
+							if err = sleep_ctx(ctx, 5000 * time.Millisecond); err != nil {
+								break
+							}
 							delta := time.Now().Sub(block_timer).Seconds()
 							self.logger.Debug("Debug", fmt.Sprintf("Waiting for contract block %v(%v) to become stable, waiting for %v seconds.", target_block, rpcResp.Result.BlockNumber, delta))
-							if int(delta) < self.tx_delay_toleration*(block_read_delay+1) {
-								if err = self.check_eth_status(); err == nil {
-									stable_block,_ := strconv.ParseUint(self.Get_stable_block()[2:], 16, 32)
+							if int(delta) < self.tx_delay_toleration*(self.blockReadDelay+1) {
+								if err = self.check_eth_status(ctx); err == nil {
+									stable_block,_ := strconv.ParseUint(self.Get_stable_block_ctx(ctx)[2:], 16, 32)
 									if target_block <= stable_block {
 										break
 									}
 								}
 							} else {
-								err = &RPCError{fmt.Sprintf("RPC transaction timed out waiting for contract block %v(%v) to be stable, after %v seconds.", target_block, rpcResp.Result.BlockNumber, delta)}
+								err = &RPCError{msg: fmt.Sprintf("RPC transaction timed out waiting for contract block %v(%v) to be stable, after %v seconds.", target_block, rpcResp.Result.BlockNumber, delta), Kind: ErrTimeout}
 							}
 						}
 					} else {
 						delta := time.Now().Sub(start_timer).Seconds()
 						if int(delta) < self.tx_delay_toleration {
 							self.logger.Debug("Debug", fmt.Sprintf("Waiting for transaction %v to run for %v seconds.", tx_address, delta))
-							time.Sleep(5000 * time.Millisecond)
-							err = self.check_eth_status()
+							if err = sleep_ctx(ctx, 5000 * time.Millisecond); err == nil {
+								err = self.check_eth_status(ctx)
+							}
 						} else {
-							err = &RPCError{fmt.Sprintf("RPC transaction receipt timed out for tx %v, after %v seconds.", tx_address, delta)}
+							err = &RPCError{msg: fmt.Sprintf("RPC transaction receipt timed out for tx %v, after %v seconds.", tx_address, delta), Kind: ErrTimeout}
 						}
 					}
 				}
@@ -540,12 +561,12 @@ func (self *SolidityContract) get_contract(tx_address string) (string, error) {
 	return result, err
 }
 
-func (self *SolidityContract) log_stats(rpcResp *rpcGetTransactionResponse) {
+func (self *SolidityContract) log_stats(ctx context.Context, rpcResp *rpcGetTransactionResponse) {
 	// If logging blockchain stats, dump them to the log
 	if self.logBlockchainStats != "" {
 		block_num := rpcResp.Result.BlockNumber
 		fmt.Printf("Tx %v cumulative gas used: %v\n", rpcResp.Result.TransactionHash, rpcResp.Result.CumulativeGasUsed)
-		if out, err := self.Call_rpc_api("eth_getBlockByNumber", MultiValueParams{block_num, false}); err != nil {
+		if out, err := self.Call_rpc_api_ctx(ctx, "eth_getBlockByNumber", MultiValueParams{block_num, false}); err != nil {
 			self.logger.Debug("Error", err.Error())
 			return
 		} else {
@@ -562,56 +583,41 @@ func (self *SolidityContract) log_stats(rpcResp *rpcGetTransactionResponse) {
 	}
 }
 
-func (self *SolidityContract) establish_event_listener() (string, error) {
+// Start following the logs of the contract, for Wait_for_event. Any previous listener is stopped.
+func (self *SolidityContract) establish_event_listener(ctx context.Context) error {
 	self.logger.Debug("Entry", "")
-	result, out, err := "", "", error(nil)
-	var rpcResp *rpcResponse = new(rpcResponse)
+	err := error(nil)
 
+	if self.listener != nil {
+		self.listener.stop(ctx)
+		self.listener = nil
+	}
 	if self.noEventlistener == false {
-		params := make(map[string]string)
-		params["address"] = self.contractAddress
-
-		if out, err = self.Call_rpc_api("eth_newFilter", params); err == nil {
-			if err = json.Unmarshal([]byte(out), rpcResp); err == nil {
-				if rpcResp.Error.Message != "" {
-					err = &RPCError{fmt.Sprintf("RPC contract deploy of %v returned an error: %v.", self.name, rpcResp.Error.Message)}
-				} else {
-					result = rpcResp.Result.(string)
-				}
-			}
-		}
+		self.listener, err = start_log_follower(ctx, self.client, FilterQuery{Address: self.contractAddress}, 0, self.logger)
 	}
 
 	if err != nil {
 		self.logger.Debug("Error", err.Error())
 	}
-	self.logger.Debug("Exit ", result)
-	return result, err
+	self.logger.Debug("Exit ", "")
+	return err
 }
 
-func (self *SolidityContract) compile_contract() (*ABI, error) {
+func (self *SolidityContract) compile_contract(ctx context.Context) (*ABI, error) {
 	self.logger.Debug("Entry", "")
 	err := error(nil)
 	var jBytes []byte
 	var result *ABI
-	var intermediate *CompiledContract
 
-	if jBytes, err = self.get_precompiled_json(); err != nil {
+	if self.compiler != nil {
+		if jBytes, err = self.compiler.Compile(ctx, self.source); err == nil {
+			result, err = self.parse_artifact(jBytes)
+		}
+	} else if jBytes, err = self.get_precompiled_json(); err != nil {
 		self.logger.Debug("Debug", fmt.Sprintf("Error reading precompiled json file for %v, %v.", self.name, err))
 
 	} else {
-		intermediate = new(CompiledContract)
-		if err = json.Unmarshal(jBytes, intermediate); err != nil {
-			err = &RPCError{fmt.Sprintf("RPC decode of precompiled contract %v returned an error: %v.", self.name, err)}
-		} else if meta, ok := intermediate.Contracts[self.name + ".sol:" + self.name]; !ok {
-			err = &RPCError{fmt.Sprintf("RPC decode of precompiled contract %v did not find data for this contract: %v.", self.name, intermediate)}
-		} else {
-			result = new(ABI)
-			result.Code = "0x" + meta.Bin
-			if err = json.Unmarshal([]byte(meta.Abi), &result.ABIDefinition); err != nil {
-				err = &RPCError{fmt.Sprintf("RPC decode of precompiled contract %v returned an error: %v.", self.name, err)}
-			}
-		}
+		result, err = self.parse_artifact(jBytes)
 	}
 
 	if err != nil {
@@ -621,18 +627,6 @@ func (self *SolidityContract) compile_contract() (*ABI, error) {
 	return result, err
 }
 
-func (self *SolidityContract) get_precompiled_json() ([]byte,error) {
-	json_file := self.name + ".json"
-	json_path := os.Getenv("mtn_contractpath")
-	if  json_path == "" {
-		json_path = os.Getenv("GOPATH") + "/src/github.com/open-horizon/go-solidity/contracts/" + json_file
-	} else {
-		json_path += json_file
-	}
-	jBytes, err := ioutil.ReadFile(json_path)
-	return jBytes, err
-}
-
 func (self *SolidityContract) getFunctionFromABI(methodName string) *abiDefEntry {
 	//self.logger.Debug("Entry",methodName)
 	abi := self.compiledContract.ABIDefinition
@@ -648,18 +642,46 @@ func (self *SolidityContract) getFunctionFromABI(methodName string) *abiDefEntry
 }
 
 func (self *SolidityContract) Call_rpc_api(method string, params interface{}) (string, error) {
+	return self.Call_rpc_api_ctx(context.Background(), method, params)
+}
+
+// Invoke an RPC method on the node. The context is passed down into the HTTP request so that the
+// call is abandoned when the context is cancelled or its deadline expires.
+func (self *SolidityContract) Call_rpc_api_ctx(ctx context.Context, method string, params interface{}) (string, error) {
 	self.logger.Debug("Entry", method, params)
 	out, err := "", error(nil)
-	var req *http.Request
-	var resp *http.Response
 	var jsonBytes []byte
 	var outBytes []byte
-	//var the_params [5]interface{}
+
+	body := self.rpc_body(ctx, method, params, "1")
+	jsonBytes, err = json.Marshal(body)
+
+	self.logger.Debug("Debug", fmt.Sprintf("RPC JSON:%v", body))
+
+	if err == nil {
+		if outBytes, err = self.client.post_rpc(ctx, is_read_method(method), method, jsonBytes); err == nil {
+			out = string(outBytes)
+		}
+	} else {
+		err = &RPCError{msg: fmt.Sprintf("RPC invocation of %v failed creating JSON body %v, error: %v", method, body, err.Error())}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+
+	self.logger.Debug("Exit ", out)
+	return out, err
+}
+
+// Build the JSON-RPC request object for a single method invocation. Each request within a batch
+// must carry a unique id so that the responses can be matched back to the requests.
+func (self *SolidityContract) rpc_body(ctx context.Context, method string, params interface{}, id string) map[string]interface{} {
 	the_params := make([]interface{}, 0, 5)
 
 	body := make(map[string]interface{})
 	body["jsonrpc"] = "2.0"
-	body["id"] = "1"
+	body["id"] = id
 	body["method"] = method
 
 	switch params.(type) {
@@ -671,45 +693,12 @@ func (self *SolidityContract) Call_rpc_api(method string, params interface{}) (s
 	default:
 		the_params = append(the_params, params)
 		if method == "eth_call" {
-			the_params = append(the_params, self.Get_stable_block())
+			the_params = append(the_params, self.Get_stable_block_ctx(ctx))
 		}
 	}
 
-	// the_params[0] = params
 	body["params"] = the_params
-	jsonBytes, err = json.Marshal(body)
-
-	self.logger.Debug("Debug", fmt.Sprintf("RPC JSON:%v", body))
-
-	if err == nil {
-		req, err = http.NewRequest("POST", self.rpcURL, bytes.NewBuffer(jsonBytes))
-		if err == nil {
-			req.Close = true			// work around to ensure that Go doesn't get connections confused. Supposed to be fixed in Go 1.6.
-			client := &http.Client{}
-			resp, err = client.Do(req)
-			if err == nil {
-				defer resp.Body.Close()
-				if outBytes, err = ioutil.ReadAll(resp.Body); err == nil {
-					out = string(outBytes)
-				} else {
-					err = &RPCError{fmt.Sprintf("RPC invocation of %v failed reading response message, error: %v", method, outBytes, err.Error())}
-				}
-			} else {
-				err = &RPCError{fmt.Sprintf("RPC http invocation of %v returned error: %v", method, err.Error())}
-			}
-		} else {
-			err = &RPCError{fmt.Sprintf("RPC invocation of %v failed creating http request, error: %v", method, err.Error())}
-		}
-	} else {
-		err = &RPCError{fmt.Sprintf("RPC invocation of %v failed creating JSON body %v, error: %v", method, body, err.Error())}
-	}
-
-	if err != nil {
-		self.logger.Debug("Error", err.Error())
-	}
-
-	self.logger.Debug("Exit ", out)
-	return out, err
+	return body
 }
 
 func (self *SolidityContract) decodeOutputString(methodName string, output_string string) (interface{}, error) {
@@ -721,34 +710,7 @@ func (self *SolidityContract) decodeOutputString(methodName string, output_strin
 	if function != nil {
 		for _, outp := range function.Outputs {
 			var value interface{}
-			if len(output_string) < 64 {
-				err = &UnsupportedValueError{fmt.Sprintf("Unable to decode output from %v because the output is not long enough.", methodName)}
-			} else if outp.Type == "address" {
-				output_string, value, err = self.decode_address(methodName, output_string)
-			} else if outp.Type == "address[]" {
-				output_string, value, err = self.decode_address_array(methodName, output_string)
-			} else if outp.Type == "bool" {
-				output_string, value, err = self.decode_boolean(methodName, output_string)
-			} else if outp.Type == "uint256" {
-				output_string, value, err = self.decode_uint256(methodName, output_string)
-			} else if outp.Type == "uint256[]" {
-				output_string, value, err = self.decode_uint256_array(methodName, output_string)
-			} else if outp.Type == "int256" {
-				output_string, value, err = self.decode_int256(methodName, output_string)
-			} else if outp.Type == "int256[]" {
-				output_string, value, err = self.decode_int256_array(methodName, output_string)
-			} else if outp.Type == "string" {
-				output_string, value, err = self.decode_string(methodName, output_string)
-			} else if outp.Type == "bytes32" {
-				output_string, value, err = self.decode_bytes32(methodName, output_string)
-			} else if outp.Type == "bytes32[]" {
-				output_string, value, err = self.decode_bytes32_array(methodName, output_string)
-			} else if outp.Type == "bytes" {
-				output_string, value, err = self.decode_bytes(methodName, output_string)
-			} else {
-				err = &UnsupportedTypeError{fmt.Sprintf("Unable to decode output from %v because type %v is not supported yet. Call Booz.", methodName, outp.Type)}
-			}
-			if err != nil {
+			if output_string, value, err = self.decode_value(methodName, outp.Type, output_string); err != nil {
 				break
 			}
 			returnValue = append(returnValue, value)
@@ -768,6 +730,40 @@ func (self *SolidityContract) decodeOutputString(methodName string, output_strin
 	}
 }
 
+// Decode one value of the given type from the front of the output, returning the rest of the output.
+func (self *SolidityContract) decode_value(methodName string, typ string, encoded_output string) (string, interface{}, error) {
+	var value interface{}
+	err := error(nil)
+	if len(encoded_output) < 64 {
+		err = &UnsupportedValueError{fmt.Sprintf("Unable to decode output from %v because the output is not long enough.", methodName)}
+	} else if typ == "address" {
+		encoded_output, value, err = self.decode_address(methodName, encoded_output)
+	} else if typ == "address[]" {
+		encoded_output, value, err = self.decode_address_array(methodName, encoded_output)
+	} else if typ == "bool" {
+		encoded_output, value, err = self.decode_boolean(methodName, encoded_output)
+	} else if typ == "uint256" {
+		encoded_output, value, err = self.decode_uint256(methodName, encoded_output)
+	} else if typ == "uint256[]" {
+		encoded_output, value, err = self.decode_uint256_array(methodName, encoded_output)
+	} else if typ == "int256" {
+		encoded_output, value, err = self.decode_int256(methodName, encoded_output)
+	} else if typ == "int256[]" {
+		encoded_output, value, err = self.decode_int256_array(methodName, encoded_output)
+	} else if typ == "string" {
+		encoded_output, value, err = self.decode_string(methodName, encoded_output)
+	} else if typ == "bytes32" {
+		encoded_output, value, err = self.decode_bytes32(methodName, encoded_output)
+	} else if typ == "bytes32[]" {
+		encoded_output, value, err = self.decode_bytes32_array(methodName, encoded_output)
+	} else if typ == "bytes" {
+		encoded_output, value, err = self.decode_bytes(methodName, encoded_output)
+	} else {
+		err = &UnsupportedTypeError{fmt.Sprintf("Unable to decode output from %v because type %v is not supported yet. Call Booz.", methodName, typ)}
+	}
+	return encoded_output, value, err
+}
+
 func (self *SolidityContract) decode_uint256(methodName string, encoded_output string) (string, uint64, error) {
 	self.logger.Debug("Entry", methodName, encoded_output)
 	remaining_output, err := "", error(nil)
@@ -800,8 +796,8 @@ func (self *SolidityContract) decode_string(methodName string, encoded_output st
 	var b []byte
 
 	if out, length, err = self.decode_uint256(methodName, encoded_output[64:]); err == nil {
-		if uint64(len(out)) < length {
-			err = &UnsupportedValueError{fmt.Sprintf("Unable to decode string output from %v because the output is shorter than required. Need %v, have %v.", methodName, length, len(out))}
+		if uint64(len(out)) < length*2 {
+			err = &UnsupportedValueError{fmt.Sprintf("Unable to decode string output from %v because the output is shorter than required. Need %v, have %v.", methodName, length*2, len(out))}
 		} else {
 			if b, err = hex.DecodeString(out[:length*2]); err == nil {
 				value = string(b)
@@ -922,8 +918,8 @@ func (self *SolidityContract) decode_bytes(methodName string, encoded_output str
 		err = &UnsupportedValueError{fmt.Sprintf("Unable to decode output from %v because the output is shorter than required. Need %v, have %v.", methodName, 64*2, len(encoded_output))}
 	} else {
 		if out, length, err = self.decode_uint256(methodName, encoded_output[64:]); err == nil {
-			if uint64(len(out)) < length {
-				err = &UnsupportedValueError{fmt.Sprintf("Unable to decode string array output from %v because the output is shorter than required. Need %v, have %v.", methodName, length, len(out))}
+			if uint64(len(out)) < length*2 {
+				err = &UnsupportedValueError{fmt.Sprintf("Unable to decode bytes output from %v because the output is shorter than required. Need %v, have %v.", methodName, length*2, len(out))}
 			} else {
 				if b, err = hex.DecodeString(out[:length*2]); err == nil {
 					remaining_output = out[length*2:]
@@ -1389,33 +1385,16 @@ func (self *SolidityContract) zero_pad_left(p string, length int) string {
 	}
 }
 
-func (self *SolidityContract) get_current_block() (string, error) {
-	var rpcResp *rpcResponse = new(rpcResponse)
-	if res,err := self.Call_rpc_api("eth_blockNumber",nil); err != nil {
-        err = &RPCError{fmt.Sprintf("RPC invocation of eth_blockNumber returned an error: %v.",err)}
-        return "", err
-    } else if err := json.Unmarshal([]byte(res),rpcResp); err != nil {
-        err = &RPCError{fmt.Sprintf("RPC invocation of eth_blockNumber returned undecodable response %v, error: %v.",res,err)}
-        return "", err
-    } else if rpcResp.Error.Message != "" {
-        err = &RPCError{fmt.Sprintf("RPC invocation of eth_blockNumber returned an error: %v.",rpcResp.Error.Message)}
-        return "", err
-    } else {
-    	switch rpcResp.Result.(type) {
-        case string:
-            if rpcResp.Result != "0x0" {
-                update_block(rpcResp.Result.(string))
-            }
-            return rpcResp.Result.(string), nil
-        default:
-        	err = &RPCError{fmt.Sprintf("RPC invocation of eth_blockNumber returned result that is not a string: %v.",rpcResp.Result)}
-        	return "", err
-        }
-    }
+func (self *SolidityContract) get_current_block(ctx context.Context) (string, error) {
+	block, err := self.client.call_string(ctx, "eth_blockNumber")
+	if err == nil && block != "" && block != "0x0" {
+		self.Get_block_tracker().update(block)
+	}
+	return block, err
 }
 
 
-func (self *SolidityContract) check_eth_status() error {
+func (self *SolidityContract) check_eth_status(ctx context.Context) error {
 	self.logger.Debug("Entry")
 	err := error(nil)
 	var res string
@@ -1426,19 +1405,29 @@ func (self *SolidityContract) check_eth_status() error {
 
     poll_wait := 5
 
+    // Try to get the complete node status in a single round trip. Fall back to polling the node one
+    // call at a time when the node is not ready yet or does not support batch requests.
+    probed := false
+    if status, perr := self.eth_status_probe(ctx); perr == nil && status.ready(self.integration_test != 0) {
+        self.Get_block_tracker().update(status.block)
+        net_done, block_done, sync_done, probed = true, true, true, true
+    } else if perr != nil {
+        self.logger.Debug("Debug", fmt.Sprintf("Batched status probe failed, polling instead: %v", perr))
+    }
+
     start_timer := time.Now()
     for !net_done && self.integration_test == 0 {
-        if res,err = self.Call_rpc_api("net_peerCount",nil); err != nil {
-            err = &RPCError{fmt.Sprintf("RPC invocation of net_peerCount returned an error: %v.",err)}
+        if res,err = self.Call_rpc_api_ctx(ctx, "net_peerCount",nil); err != nil {
+            err = wrap_rpc_error(fmt.Sprintf("RPC invocation of net_peerCount returned an error: %v.",err), err)
             break
         }
         if err = json.Unmarshal([]byte(res),rpcResp); err != nil {
-            err = &RPCError{fmt.Sprintf("RPC invocation of net_peerCount returned undecodable response %v, error: %v.",res,err)}
+            err = &RPCError{msg: fmt.Sprintf("RPC invocation of net_peerCount returned undecodable response %v, error: %v.",res,err)}
             break
         }
 
         if rpcResp.Error.Message != "" {
-            err = &RPCError{fmt.Sprintf("RPC invocation of net_peerCount returned an error: %v.",rpcResp.Error.Message)}
+            err = rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of net_peerCount returned an error: %v.",rpcResp.Error.Message))
             break
         } else {
             switch rpcResp.Result.(type) {
@@ -1454,10 +1443,12 @@ func (self *SolidityContract) check_eth_status() error {
             }
             delta := time.Now().Sub(start_timer).Seconds()
 			if int(delta) < self.sync_delay_toleration {
-				time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
+				if err = sleep_ctx(ctx, time.Duration(poll_wait)*1000*time.Millisecond); err != nil {
+					break
+				}
 				self.logger.Debug("Debug", fmt.Sprintf("Waiting for non-zero peer count for %v seconds.", delta))
 			} else {
-				err = &RPCError{fmt.Sprintf("Peer count check timed out, after %v seconds.", delta)}
+				err = &RPCError{msg: fmt.Sprintf("Peer count check timed out, after %v seconds.", delta), Kind: ErrTimeout}
 				break
 			}
         }
@@ -1466,7 +1457,7 @@ func (self *SolidityContract) check_eth_status() error {
     start_timer = time.Now()
     block := ""
     for !block_done && err == nil {
-    	if block, err = self.get_current_block(); err != nil {
+    	if block, err = self.get_current_block(ctx); err != nil {
     		break
         // if res,err = self.Call_rpc_api("eth_blockNumber",nil); err != nil {
         //     err = &RPCError{fmt.Sprintf("RPC invocation of eth_blockNumber returned an error: %v.",err)}
@@ -1496,10 +1487,12 @@ func (self *SolidityContract) check_eth_status() error {
         } else {
             delta := time.Now().Sub(start_timer).Seconds()
 			if int(delta) < self.sync_delay_toleration {
-				time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
+				if err = sleep_ctx(ctx, time.Duration(poll_wait)*1000*time.Millisecond); err != nil {
+					break
+				}
 				self.logger.Debug("Debug", fmt.Sprintf("Waiting for non-zero block count for %v seconds.", delta))
 			} else {
-				err = &RPCError{fmt.Sprintf("Block count check timed out, after %v seconds.", delta)}
+				err = &RPCError{msg: fmt.Sprintf("Block count check timed out, after %v seconds.", delta), Kind: ErrTimeout}
 				break
 			}
         }
@@ -1507,10 +1500,10 @@ func (self *SolidityContract) check_eth_status() error {
 
     start_timer = time.Now()
     for !sync_done && err == nil {
-        if res,err = self.Call_rpc_api("eth_syncing",nil); err == nil {
+        if res,err = self.Call_rpc_api_ctx(ctx, "eth_syncing",nil); err == nil {
             if err = json.Unmarshal([]byte(res),rpcResp); err == nil {
                 if rpcResp.Error.Message != "" {
-                    err = &RPCError{fmt.Sprintf("RPC invocation of eth_syncing returned an error: %v.",rpcResp.Error.Message)}
+                    err = rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of eth_syncing returned an error: %v.",rpcResp.Error.Message))
                     break
                 } else {
                     switch rpcResp.Result.(type) {
@@ -1526,28 +1519,30 @@ func (self *SolidityContract) check_eth_status() error {
             		}
                     delta := time.Now().Sub(start_timer).Seconds()
 					if int(delta) < self.sync_delay_toleration {
-						time.Sleep(time.Duration(poll_wait)*1000*time.Millisecond)
+						if err = sleep_ctx(ctx, time.Duration(poll_wait)*1000*time.Millisecond); err != nil {
+							break
+						}
 						self.logger.Debug("Debug", fmt.Sprintf("Waiting for syncing to complete for %v seconds.", delta))
 					} else {
-						err = &RPCError{fmt.Sprintf("Sync check timed out, after %v seconds.", delta)}
+						err = &RPCError{msg: fmt.Sprintf("Sync check timed out, after %v seconds.", delta), Kind: ErrTimeout}
 						break
 					}
                 }
             } else {
-                err = &RPCError{fmt.Sprintf("RPC invocation of eth_syncing returned undecodable response %v, error: %v.",res,err)}
+                err = &RPCError{msg: fmt.Sprintf("RPC invocation of eth_syncing returned undecodable response %v, error: %v.",res,err)}
             	break
             }
         } else {
-            err = &RPCError{fmt.Sprintf("RPC invocation of eth_syncing returned an error: %v.",err)}
+            err = wrap_rpc_error(fmt.Sprintf("RPC invocation of eth_syncing returned an error: %v.",err), err)
             break
         }
     }
 
-    if err == nil {
-        if res,err = self.Call_rpc_api("eth_getBalance",MultiValueParams{self.from, "latest"}); err == nil {
+    if err == nil && !probed {
+        if res,err = self.Call_rpc_api_ctx(ctx, "eth_getBalance",MultiValueParams{self.from, "latest"}); err == nil {
 	        if err = json.Unmarshal([]byte(res),rpcResp); err == nil {
 	            if rpcResp.Error.Message != "" {
-	                err = &RPCError{fmt.Sprintf("RPC invocation of eth_getBalance returned an error: %v.",rpcResp.Error.Message)}
+	                err = rpcResp.Error.wrap(fmt.Sprintf("RPC invocation of eth_getBalance returned an error: %v.",rpcResp.Error.Message))
 	            } else {
 	                switch rpcResp.Result.(type) {
 	                    case string:
@@ -1556,25 +1551,25 @@ func (self *SolidityContract) check_eth_status() error {
 					        // the math/big library doesn't like leading "0x" on hex strings
 					        bal.SetString(bal_hex_str[2:],16)
 	                        if bal.Cmp(big.NewInt(1500000)) < 1 {
-	                            err = &RPCError{fmt.Sprintf("Out of ether, have: %v.",rpcResp.Result)}
+	                            err = &RPCError{msg: fmt.Sprintf("Out of ether, have: %v.",rpcResp.Result)}
 	                        }
 	                    default:
 	                }
 
 	            }
 	        } else {
-	            err = &RPCError{fmt.Sprintf("RPC invocation of eth_getBalance returned undecodable response %v, error: %v.",res,err)}
+	            err = &RPCError{msg: fmt.Sprintf("RPC invocation of eth_getBalance returned undecodable response %v, error: %v.",res,err)}
 	        }
 	    } else {
-	        err = &RPCError{fmt.Sprintf("RPC invocation of eth_getBalance returned an error: %v.",err)}
+	        err = wrap_rpc_error(fmt.Sprintf("RPC invocation of eth_getBalance returned an error: %v.",err), err)
 	    }
 	}
 
-    if err == nil && blocks_stopped() {
-        err = &RPCError{fmt.Sprintf("No new blocks received in last %v seconds. Last block was %v.", no_recent_blocks, global_block_state.blockNumber)}
+    if err == nil && self.Get_block_tracker().stopped(self.noRecentBlocks) {
+        err = &RPCError{msg: fmt.Sprintf("No new blocks received in last %v seconds. Last block was %v.", self.noRecentBlocks, self.Get_block_tracker().Current())}
     }
 
-    self.logger.Debug("Debug", fmt.Sprintf("Global block %v", global_block_state))
+    self.logger.Debug("Debug", fmt.Sprintf("Block tracker %v", self.Get_block_tracker()))
 
 	if err != nil {
 		self.logger.Debug("Error", err.Error())
@@ -1625,8 +1620,30 @@ func (e *UnsupportedValueError) Error() string {
 	}
 }
 
+// The kinds of RPC failure that callers commonly need to handle. Use errors.Is to test an error
+// returned by this package against one of these, e.g. errors.Is(err, ErrNonceTooLow).
+var (
+	ErrNonceTooLow       = errors.New("nonce too low")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrFilterNotFound    = errors.New("filter not found")
+	ErrTimeout           = errors.New("timeout")
+	ErrRevert            = errors.New("execution reverted")
+	ErrLimitExceeded     = errors.New("limit exceeded")
+)
+
+// An error from an RPC call. When the node answered with a JSON-RPC error, Code, Message and Data
+// hold the error object exactly as the node returned it. Kind is one of the Err sentinels above, or
+// nil when the failure doesn't fall into any of them, and Transient is true when the call never got
+// a usable answer from the node, so trying it again might succeed. Use errors.As to get at these
+// fields.
 type RPCError struct {
-	msg string
+	msg       string
+	Code      int
+	Message   string
+	Data      interface{}
+	Kind      error
+	Transient bool
+	cause     error
 }
 
 func (e *RPCError) Error() string {
@@ -1637,6 +1654,64 @@ func (e *RPCError) Error() string {
 	}
 }
 
+// Return the underlying error, e.g. the transport error or the context error, if there is one.
+func (e *RPCError) Unwrap() error {
+	if e != nil {
+		return e.cause
+	} else {
+		return nil
+	}
+}
+
+// Allow errors.Is to match the error against its kind.
+func (e *RPCError) Is(target error) bool {
+	return e != nil && e.Kind != nil && e.Kind == target
+}
+
+// Work out the kind of a JSON-RPC error from its code and message. Nodes don't agree on the codes,
+// so the message is the more reliable guide.
+func classify_rpc_error(code int, message string) error {
+	msg := strings.ToLower(message)
+	switch {
+	case strings.Contains(msg, "nonce too low"):
+		return ErrNonceTooLow
+	case strings.Contains(msg, "insufficient funds"):
+		return ErrInsufficientFunds
+	case strings.Contains(msg, "filter not found"):
+		return ErrFilterNotFound
+	case code == 3 || strings.Contains(msg, "revert"):
+		return ErrRevert
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"):
+		return ErrTimeout
+	case code == -32005 || strings.Contains(msg, "more than") || strings.Contains(msg, "too many") || strings.Contains(msg, "response size") ||
+		(strings.Contains(msg, "range") && (strings.Contains(msg, "exceed") || strings.Contains(msg, "too") || strings.Contains(msg, "limit"))):
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// Wrap an error returned by an RPC call in an RPCError with a message saying what was being done,
+// keeping the code, kind and cause of the original.
+func wrap_rpc_error(msg string, err error) *RPCError {
+	wrapped := &RPCError{msg: msg, cause: err}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		wrapped.Code, wrapped.Message, wrapped.Data = rpcErr.Code, rpcErr.Message, rpcErr.Data
+		wrapped.Kind, wrapped.Transient = rpcErr.Kind, rpcErr.Transient
+	}
+	return wrapped
+}
+
+// Wrap an error returned by the transport or the context in an RPCError.
+func transport_error(msg string, cause error, transient bool) *RPCError {
+	err := &RPCError{msg: msg, Transient: transient, cause: cause}
+	var netErr net.Error
+	if errors.Is(cause, context.DeadlineExceeded) || (errors.As(cause, &netErr) && netErr.Timeout()) {
+		err.Kind = ErrTimeout
+	}
+	return err
+}
+
 type DeployError struct {
 	msg string
 }
@@ -1661,28 +1736,143 @@ func (e *LoadError) Error() string {
 	}
 }
 
+type CircuitOpenError struct {
+	msg string
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type CodeMismatchError struct {
+	msg string
+}
+
+func (e *CodeMismatchError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type CompileError struct {
+	msg string
+}
+
+func (e *CompileError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type LinkError struct {
+	msg string
+}
+
+func (e *LinkError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type ConfigError struct {
+	msg string
+}
+
+func (e *ConfigError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type HTTPConfigError struct {
+	msg string
+}
+
+func (e *HTTPConfigError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type CheckpointError struct {
+	msg string
+}
+
+func (e *CheckpointError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type EventFilterError struct {
+	msg string
+}
+
+func (e *EventFilterError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+type SignatureError struct {
+	msg string
+}
+
+func (e *SignatureError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
 // ============================================================================
 // Structs returned by the compiler RPC
 //
 
+// The error object of a JSON-RPC response. Message is empty when the call succeeded.
+type rpcErrorObject struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+// Turn the error object into an RPCError with the given message, keeping the node's code, message
+// and data.
+func (self *rpcErrorObject) wrap(msg string) *RPCError {
+	return &RPCError{msg: msg, Code: self.Code, Message: self.Message, Data: self.Data, Kind: classify_rpc_error(self.Code, self.Message)}
+}
+
 type rpcResponse struct {
 	Id      string      `json:"id"`
 	Version string      `json:"jsonrpc"`
 	Result  interface{} `json:"result"`
-	Error   struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+	Error   rpcErrorObject `json:"error"`
 }
 
 type rpcGetBlockByNumberResponse struct {
 	Id      string     `json:"id"`
 	Version string     `json:"jsonrpc"`
 	Result  rpcBlock   `json:"result"`
-	Error   struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+	Error   rpcErrorObject `json:"error"`
 }
 
 type rpcBlock struct {
@@ -1711,10 +1901,7 @@ type rpcGetTransactionResponse struct {
 	Id      string         `json:"id"`
 	Version string         `json:"jsonrpc"`
 	Result  rpcTranReceipt `json:"result"`
-	Error   struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+	Error   rpcErrorObject `json:"error"`
 }
 
 type rpcTranReceipt struct {
@@ -1728,35 +1915,17 @@ type rpcTranReceipt struct {
 	Logs              []interface{} `json:"logs"`
 }
 
-type rpcGetFilterChangesResponse struct {
-	Id      string             `json:"id"`
-	Version string             `json:"jsonrpc"`
-	Result  []rpcFilterChanges `json:"result"`
-	Error   struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
-}
-
-type rpcFilterChanges struct {
-	LogIndex         string   `json:"logIndex"`
-	TransactionHash  string   `json:"transactionHash"`
-	TransactionIndex string   `json:"transactionIndex"`
-	BlockNumber      string   `json:"blockNumber"`
-	BlockHash        string   `json:"blockHash"`
-	Address          string   `json:"address"`
-	Data             string   `json:"data"`
-	Topics           []string `json:"topics"`
-}
-
 type abiDefEntry struct {
 	Inputs []struct {
-		Type string `json:"type"`
-		Name string `json:"name"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Indexed bool   `json:"indexed"`
 	} `json:"inputs"`
-	Type     string `json:"type"`
-	Constant bool   `json:"constant"`
-	Name     string `json:"name"`
+	Type            string `json:"type"`
+	Constant        bool   `json:"constant"`
+	Anonymous       bool   `json:"anonymous"`
+	StateMutability string `json:"stateMutability"`
+	Name            string `json:"name"`
 	Outputs  []struct {
 		Type string `json:"type"`
 		Name string `json:"name"`
@@ -1765,6 +1934,8 @@ type abiDefEntry struct {
 
 type ABI struct {
 	Code          string        `json:"code"`
+	RuntimeCode   string        `json:"runtimeCode"` // The code left on the chain by the deploy, when the artifact has it
+	Metadata      string        `json:"metadata"`    // The compiler's metadata JSON, when the artifact has it
 	ABIDefinition []abiDefEntry `json:"abi"`
 }
 
@@ -1789,3 +1960,15 @@ type CompiledContract struct {
 
 type MultiValueParams []interface{}
 
+// Sleep for the given duration, returning early with the context's error if the context is
+// cancelled or its deadline expires first.
+func sleep_ctx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return transport_error(fmt.Sprintf("Wait abandoned: %v", ctx.Err()), ctx.Err(), false)
+	case <-timer.C:
+		return nil
+	}
+}
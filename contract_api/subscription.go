@@ -0,0 +1,470 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Selects the logs delivered to a subscription. Event names an event in the contract interface,
+// matched by the hash of its signature in the first topic unless the event is anonymous. Params
+// matches indexed parameters of that event by name, against a value or a []interface{} of
+// alternatives. Topics matches topic positions directly; each position holds the alternatives for
+// that topic as 0x prefixed hex and an empty position matches anything. Params and Topics can't
+// both constrain the same position.
+//
+// Logs can be undone by a chain reorganisation. With no Confirmations, logs are delivered at once,
+// and a log that is undone is delivered again as a retraction, with Removed set. With Confirmations,
+// logs are only delivered once that many blocks have been built on top of their block, so that
+// retractions are rare.
+type EventFilter struct {
+	Event         string
+	Params        map[string]interface{}
+	Topics        [][]string
+	Confirmations uint64
+}
+
+// A log delivered to a subscription. Name, Indexed and Data are only set when the log was emitted
+// by an event in the contract interface. Indexed holds the indexed parameters by name; addresses,
+// numbers and booleans are decoded, other types are left as the topic, which for strings and arrays
+// is the hash of the value. Data holds the other parameters by name, decoded from the log data the
+// way method outputs are; it is left out when the data can't be decoded. Removed is set when the
+// event retracts an event delivered earlier, because a chain reorganisation undid it.
+type Event struct {
+	Name    string
+	Indexed map[string]interface{}
+	Data    map[string]interface{}
+	Removed bool
+	Log     Log
+}
+
+// Called with each log delivered to a subscription, in the order the logs were emitted.
+type EventHandler func(ev *Event)
+
+// A subscription to the logs of a contract. Each subscription has its own filter on the node,
+// which it polls from its own goroutine. A filter dropped by the node is created again, and the logs
+// emitted in the meantime are still delivered.
+type Subscription struct {
+	contract *SolidityContract
+	event    *abiDefEntry // The event named by the filter, if any
+	topics   [][]string
+	follower *log_follower
+	handler  EventHandler
+	events   chan<- *Event
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+}
+
+// Call the handler with each log of the contract that matches the filter, until the context is
+// cancelled or Unsubscribe is called. The handler is called from the subscription's goroutine, so
+// a slow handler delays the delivery of later logs but not those of other subscriptions.
+func (self *SolidityContract) Subscribe(ctx context.Context, filter *EventFilter, handler EventHandler) (*Subscription, error) {
+	return self.subscribe(ctx, filter, &Subscription{handler: handler})
+}
+
+// Send each log of the contract that matches the filter on the channel, until the context is
+// cancelled or Unsubscribe is called. The channel is not closed when the subscription ends; use
+// Done for that.
+func (self *SolidityContract) Subscribe_channel(ctx context.Context, filter *EventFilter, events chan<- *Event) (*Subscription, error) {
+	return self.subscribe(ctx, filter, &Subscription{events: events})
+}
+
+func (self *SolidityContract) subscribe(ctx context.Context, filter *EventFilter, sub *Subscription) (*Subscription, error) {
+	self.logger.Debug("Entry", filter)
+	err := error(nil)
+	sub.contract = self
+	sub.done = make(chan struct{})
+
+	if filter == nil {
+		err = &EventFilterError{fmt.Sprintf("Unable to subscribe to the events of contract %v without a filter.", self.name)}
+	} else if sub.handler == nil && sub.events == nil {
+		err = &EventFilterError{fmt.Sprintf("Unable to subscribe to the events of contract %v without a handler or a channel.", self.name)}
+	} else if self.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before subscribing to events.\n")}
+	} else if err = self.ensure_verified(ctx); err == nil {
+		sub.event, sub.topics, err = self.filter_topics(filter)
+	}
+
+	if err == nil {
+		query := FilterQuery{Address: self.contractAddress, Topics: topic_query(sub.topics)}
+		if sub.follower, err = start_log_follower(ctx, self.client, query, filter.Confirmations, self.logger); err == nil {
+			var sub_ctx context.Context
+			sub_ctx, sub.cancel = context.WithCancel(ctx)
+			self.subscriptionsLock.Lock()
+			self.subscriptions[sub] = true
+			self.subscriptionsLock.Unlock()
+			go sub.run(sub_ctx)
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+		return nil, err
+	}
+	self.logger.Debug("Exit ", sub.follower.filterID)
+	return sub, nil
+}
+
+// Return the subscriptions of the contract that have not ended.
+func (self *SolidityContract) Get_subscriptions() []*Subscription {
+	self.subscriptionsLock.Lock()
+	defer self.subscriptionsLock.Unlock()
+	subs := make([]*Subscription, 0, len(self.subscriptions))
+	for sub := range self.subscriptions {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// End all the subscriptions of the contract.
+func (self *SolidityContract) Unsubscribe_all() {
+	for _, sub := range self.Get_subscriptions() {
+		sub.Unsubscribe()
+	}
+}
+
+// End the subscription and remove its filter from the node. Once Unsubscribe returns, no more logs
+// are delivered.
+func (self *Subscription) Unsubscribe() {
+	self.cancel()
+	<-self.done
+}
+
+// Closed when the subscription has ended.
+func (self *Subscription) Done() <-chan struct{} {
+	return self.done
+}
+
+// Return the error that ended the subscription, or nil if it is still running or was ended by
+// Unsubscribe or its context.
+func (self *Subscription) Err() error {
+	select {
+	case <-self.done:
+		return self.err
+	default:
+		return nil
+	}
+}
+
+func (self *Subscription) run(ctx context.Context) {
+	defer self.stop()
+	for {
+		logs, err := self.follower.poll(ctx)
+		if err == nil {
+			for ix := range logs {
+				if match_topics(self.topics, logs[ix].Topics) {
+					self.deliver(ctx, self.contract.decode_event(self.event, &logs[ix]))
+				}
+			}
+			err = sleep_ctx(ctx, self.contract.pollInterval)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				self.contract.logger.Debug("Error", fmt.Sprintf("Subscription to %v ended, error: %v", self.contract.contractAddress, err))
+				self.err = err
+			}
+			return
+		}
+	}
+}
+
+func (self *Subscription) deliver(ctx context.Context, ev *Event) {
+	if self.events == nil {
+		self.handler(ev)
+	} else {
+		select {
+		case self.events <- ev:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// Forget the subscription and remove its filter from the node. A filter that can't be removed is
+// dropped by the node after a few minutes without polls anyway.
+func (self *Subscription) stop() {
+	self.contract.subscriptionsLock.Lock()
+	delete(self.contract.subscriptions, self)
+	self.contract.subscriptionsLock.Unlock()
+	self.follower.stop(context.Background())
+	close(self.done)
+}
+
+func (self *SolidityContract) getEventFromABI(eventName string) *abiDefEntry {
+	if self.compiledContract == nil {
+		return nil
+	}
+	abi := self.compiledContract.ABIDefinition
+	for ix := range abi {
+		if abi[ix].Type == "event" && abi[ix].Name == eventName {
+			return &abi[ix]
+		}
+	}
+	return nil
+}
+
+// Return the event named by the filter and the alternatives for each topic position.
+func (self *SolidityContract) filter_topics(filter *EventFilter) (*abiDefEntry, [][]string, error) {
+	topics := make([][]string, 0, 4)
+	for _, alts := range filter.Topics {
+		lower := make([]string, 0, len(alts))
+		for _, t := range alts {
+			lower = append(lower, strings.ToLower(t))
+		}
+		topics = append(topics, lower)
+	}
+
+	if filter.Event == "" {
+		if len(filter.Params) != 0 {
+			return nil, nil, &EventFilterError{fmt.Sprintf("Unable to filter on parameters %v of contract %v without an event.", filter.Params, self.name)}
+		}
+		return nil, topics, nil
+	}
+
+	event := self.getEventFromABI(filter.Event)
+	if event == nil {
+		return nil, nil, &EventFilterError{fmt.Sprintf("Unable to filter on event %v because it is not found in the interface of contract %v.", filter.Event, self.name)}
+	}
+
+	err := error(nil)
+	pos := 0
+	if !event.Anonymous {
+		if topics, err = set_topic(topics, 0, []string{event_topic(event)}); err != nil {
+			return nil, nil, err
+		}
+		pos = 1
+	}
+	positions := make(map[string]int)
+	types := make(map[string]string)
+	for _, inp := range event.Inputs {
+		if inp.Indexed {
+			positions[inp.Name], types[inp.Name] = pos, inp.Type
+			pos += 1
+		}
+	}
+	for name, value := range filter.Params {
+		if _, indexed := positions[name]; !indexed {
+			return nil, nil, &EventFilterError{fmt.Sprintf("Unable to filter on %v because it is not an indexed parameter of event %v.", name, filter.Event)}
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		alts := make([]string, 0, len(values))
+		for _, v := range values {
+			t, err := self.encode_topic(filter.Event, types[name], v)
+			if err != nil {
+				return nil, nil, err
+			}
+			alts = append(alts, t)
+		}
+		if topics, err = set_topic(topics, positions[name], alts); err != nil {
+			return nil, nil, err
+		}
+	}
+	return event, topics, nil
+}
+
+// Return the topics with the alternatives set at the given position, unless the position is
+// constrained already.
+func set_topic(topics [][]string, pos int, alts []string) ([][]string, error) {
+	if pos > 3 {
+		return topics, &EventFilterError{fmt.Sprintf("Unable to filter on topic %v, logs have at most 4 topics.", pos)}
+	}
+	for len(topics) <= pos {
+		topics = append(topics, nil)
+	}
+	if len(topics[pos]) != 0 {
+		return topics, &EventFilterError{fmt.Sprintf("Unable to filter on topic %v twice, have %v and %v.", pos, topics[pos], alts)}
+	}
+	topics[pos] = alts
+	return topics, nil
+}
+
+// Return the topics in the form taken by eth_newFilter; null for any value, a string for a single
+// value and an array for alternatives.
+func topic_query(topics [][]string) []interface{} {
+	query := make([]interface{}, 0, len(topics))
+	for _, alts := range topics {
+		switch len(alts) {
+		case 0:
+			query = append(query, nil)
+		case 1:
+			query = append(query, alts[0])
+		default:
+			query = append(query, alts)
+		}
+	}
+	for len(query) > 0 && query[len(query)-1] == nil {
+		query = query[:len(query)-1]
+	}
+	return query
+}
+
+// Nodes apply the filter already; checking again keeps nodes that ignore topics from delivering
+// logs the subscription didn't ask for.
+func match_topics(topics [][]string, log_topics []string) bool {
+	for pos, alts := range topics {
+		if len(alts) == 0 {
+			continue
+		} else if pos >= len(log_topics) {
+			return false
+		}
+		found := false
+		for _, t := range alts {
+			if strings.EqualFold(t, log_topics[pos]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the topic of an event; the hash of its signature.
+func event_topic(event *abiDefEntry) string {
+	sig := event.Name + "("
+	for _, inp := range event.Inputs {
+		sig += inp.Type + ","
+	}
+	sig = strings.TrimSuffix(sig, ",") + ")"
+	return keccak256([]byte(sig))
+}
+
+func keccak256(b []byte) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(b)
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Encode the value of an indexed parameter the way it appears in a topic. Values of dynamic types
+// are hashed.
+func (self *SolidityContract) encode_topic(eventName string, typ string, value interface{}) (string, error) {
+	res, err := "", error(nil)
+	switch {
+	case typ == "address":
+		res, err = self.encode_address(eventName, value)
+	case typ == "bool":
+		res, err = self.encode_boolean(eventName, value)
+	case typ == "bytes32":
+		res, err = self.encode_bytes32(eventName, value)
+	case strings.HasPrefix(typ, "uint") || strings.HasPrefix(typ, "int"):
+		res, err = self.encode_uint256(eventName, value)
+	case typ == "string" || typ == "bytes":
+		switch v := value.(type) {
+		case string:
+			return keccak256([]byte(v)), nil
+		case []byte:
+			return keccak256(v), nil
+		default:
+			err = &UnsupportedTypeError{fmt.Sprintf("Unable to filter on %v because parameter %v is not a string or byte array.", eventName, value)}
+		}
+	default:
+		err = &UnsupportedTypeError{fmt.Sprintf("Unable to filter on %v because type %v is not supported yet.", eventName, typ)}
+	}
+	return "0x" + res, err
+}
+
+// Decode the log as the named event of the contract. A log of an anonymous event can't be
+// recognized from its topics, so a contract that emits several anonymous events, told apart by one
+// of their parameters, needs the event to be named to decode the rest of the log.
+func (self *SolidityContract) Decode_event_as(event_name string, log *Log) (*Event, error) {
+	event := self.getEventFromABI(event_name)
+	if event == nil {
+		return nil, &EventFilterError{fmt.Sprintf("Unable to decode log as event %v because it is not found in the interface of contract %v.", event_name, self.name)}
+	}
+	return self.decode_event(event, log), nil
+}
+
+// Return the log as an event of the contract. A log of an anonymous event can only be recognized
+// through the filter that delivered it.
+func (self *SolidityContract) decode_event(event *abiDefEntry, log *Log) *Event {
+	ev := &Event{Log: *log, Removed: log.Removed}
+	if event == nil && len(log.Topics) > 0 && self.compiledContract != nil {
+		for ix, entry := range self.compiledContract.ABIDefinition {
+			if entry.Type == "event" && !entry.Anonymous && strings.EqualFold(event_topic(&entry), log.Topics[0]) {
+				event = &self.compiledContract.ABIDefinition[ix]
+				break
+			}
+		}
+	}
+	if event == nil {
+		return ev
+	}
+
+	ev.Name = event.Name
+	ev.Indexed = make(map[string]interface{})
+	pos := 0
+	if !event.Anonymous {
+		pos = 1
+	}
+	for _, inp := range event.Inputs {
+		if inp.Indexed && pos < len(log.Topics) {
+			ev.Indexed[inp.Name] = self.decode_topic(event.Name, inp.Type, log.Topics[pos])
+			pos += 1
+		}
+	}
+	if data, err := self.decode_event_data(event, strings.TrimPrefix(log.Data, "0x")); err == nil {
+		ev.Data = data
+	}
+	return ev
+}
+
+// Decode the parameters of the event that aren't indexed from the log data. The data starts with a
+// word for each parameter, which for a string, bytes or an array is the offset of its value.
+func (self *SolidityContract) decode_event_data(event *abiDefEntry, data string) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	head, err := data, error(nil)
+	for _, inp := range event.Inputs {
+		if inp.Indexed {
+			continue
+		}
+		var value interface{}
+		if inp.Type == "string" || inp.Type == "bytes" || strings.HasSuffix(inp.Type, "[]") {
+			// The decoders expect the value to follow its offset word.
+			if len(head) < 64 {
+				err = &UnsupportedValueError{fmt.Sprintf("Unable to decode %v of event %v because the data is not long enough.", inp.Name, event.Name)}
+			} else if offset := Hex_to_uint64("0x" + head[:64]); offset*2 > uint64(len(data)) {
+				err = &UnsupportedValueError{fmt.Sprintf("Unable to decode %v of event %v because its offset %v is past the end of the data.", inp.Name, event.Name, offset)}
+			} else if _, value, err = self.decode_value(event.Name, inp.Type, head[:64]+data[offset*2:]); err == nil {
+				head = head[64:]
+			}
+		} else {
+			head, value, err = self.decode_value(event.Name, inp.Type, head)
+		}
+		if err != nil {
+			return nil, err
+		}
+		res[inp.Name] = value
+	}
+	return res, nil
+}
+
+func (self *SolidityContract) decode_topic(eventName string, typ string, topic string) interface{} {
+	t := strings.TrimPrefix(topic, "0x")
+	if len(t) != 64 {
+		return topic
+	}
+	var value interface{}
+	err := error(nil)
+	switch {
+	case typ == "address":
+		_, value, err = self.decode_address(eventName, t)
+	case typ == "bool":
+		_, value, err = self.decode_boolean(eventName, t)
+	case strings.HasPrefix(typ, "uint") || strings.HasPrefix(typ, "int"):
+		_, value, err = self.decode_uint256(eventName, t)
+	default:
+		return topic
+	}
+	if err != nil {
+		return topic
+	}
+	return value
+}
@@ -0,0 +1,164 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testEventABI = `[
+	{"type": "event", "name": "Transfer", "inputs": [{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256", "indexed": false}]},
+	{"type": "event", "name": "Note", "anonymous": true, "inputs": [{"name": "tag", "type": "bytes32", "indexed": true}, {"name": "count", "type": "uint256", "indexed": true}]}
+]`
+
+const (
+	testTransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	testFrom          = "0x1111111111111111111111111111111111111111"
+	testToA           = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	testToB           = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	testToC           = "0xcccccccccccccccccccccccccccccccccccccccc"
+)
+
+func testTopic(addr string) string {
+	return "0x000000000000000000000000" + addr[2:]
+}
+
+func testEventContract(t *testing.T, url string) *SolidityContract {
+	sc, _ := SolidityContractFactoryWithOptions("some_contract", WithRPCURL(url), WithPollInterval(10*time.Millisecond))
+	sc.Set_skip_eventlistener()
+	abi := &ABI{}
+	if err := json.Unmarshal([]byte(testEventABI), &abi.ABIDefinition); err != nil {
+		t.Fatalf("Error unmarshalling test ABI, error: %v\n", err)
+	}
+	sc.Set_compiled_contract(abi)
+	sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+	return sc
+}
+
+func TestSubscribe(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{"eth_getFilterChanges": []interface{}{}, "eth_uninstallFilter": true, "eth_newFilter": "0x3", "eth_blockNumber": "0xf"}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	sc := testEventContract(t, server.URL)
+
+	if topic := event_topic(sc.getEventFromABI("Transfer")); topic != testTransferTopic {
+		t.Errorf("event_topic returned %v, expected %v\n", topic, testTransferTopic)
+	}
+
+	seven := "0x0000000000000000000000000000000000000000000000000000000000000007"
+	tag := "0x6e6f746500000000000000000000000000000000000000000000000000000000"
+	logs := []interface{}{
+		map[string]interface{}{"blockNumber": "0x10", "logIndex": "0x0", "topics": []string{testTransferTopic, testTopic(testFrom), testTopic(testToC)}},
+		map[string]interface{}{"blockNumber": "0x10", "logIndex": "0x1", "topics": []string{testTransferTopic, testTopic(testFrom), testTopic(testToA)}},
+		map[string]interface{}{"blockNumber": "0x11", "logIndex": "0x0", "topics": []string{tag, seven}},
+	}
+	node.queue("eth_newFilter", "0x1")
+	node.queue("eth_newFilter", "0x2")
+	node.queue("eth_getFilterChanges 0x1", logs)
+	node.queue("eth_getFilterChanges 0x2", logs)
+
+	// A named event, with alternatives for an indexed parameter, delivered to a handler.
+	transfers := make(chan *Event, 10)
+	sub1, err := sc.Subscribe(context.Background(), &EventFilter{Event: "Transfer", Params: map[string]interface{}{"to": []interface{}{testToA, testToB}}}, func(ev *Event) { transfers <- ev })
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v\n", err)
+	}
+	expected := []interface{}{map[string]interface{}{"address": "0xb37e8570f16682474894d435b207bb9a67dec3d9", "topics": []interface{}{testTransferTopic, nil, []interface{}{testTopic(testToA), testTopic(testToB)}}}}
+	if params := node.last_params("eth_newFilter"); !reflect.DeepEqual(params, expected) {
+		t.Errorf("Subscribe created filter %v, expected %v\n", params, expected)
+	}
+
+	// An anonymous event, filtered by its second indexed parameter, delivered on a channel.
+	notes := make(chan *Event, 10)
+	if _, err := sc.Subscribe_channel(context.Background(), &EventFilter{Event: "Note", Params: map[string]interface{}{"count": 7}}, notes); err != nil {
+		t.Fatalf("Subscribe_channel returned error: %v\n", err)
+	}
+	expected = []interface{}{map[string]interface{}{"address": "0xb37e8570f16682474894d435b207bb9a67dec3d9", "topics": []interface{}{nil, seven}}}
+	if params := node.last_params("eth_newFilter"); !reflect.DeepEqual(params, expected) {
+		t.Errorf("Subscribe_channel created filter %v, expected %v\n", params, expected)
+	}
+	if subs := sc.Get_subscriptions(); len(subs) != 2 {
+		t.Errorf("Get_subscriptions returned %v subscriptions, expected 2\n", len(subs))
+	}
+
+	select {
+	case ev := <-transfers:
+		if ev.Name != "Transfer" || ev.Indexed["to"] != testToA || ev.Indexed["from"] != testFrom {
+			t.Errorf("Subscribe delivered %v %v, expected a transfer to %v\n", ev.Name, ev.Indexed, testToA)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Subscribe delivered no transfer\n")
+	}
+	select {
+	case ev := <-notes:
+		if ev.Name != "Note" || ev.Indexed["count"] != uint64(7) || ev.Indexed["tag"] != tag {
+			t.Errorf("Subscribe_channel delivered %v %v, expected a note\n", ev.Name, ev.Indexed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Subscribe_channel delivered no note\n")
+	}
+	select {
+	case ev := <-transfers:
+		t.Errorf("Subscribe delivered a log that doesn't match the filter: %v\n", ev.Log)
+	case ev := <-notes:
+		t.Errorf("Subscribe_channel delivered a log that doesn't match the filter: %v\n", ev.Log)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sc.Unsubscribe_all()
+	if subs := sc.Get_subscriptions(); len(subs) != 0 {
+		t.Errorf("Unsubscribe_all left %v subscriptions\n", len(subs))
+	}
+	select {
+	case <-sub1.Done():
+		if sub1.Err() != nil {
+			t.Errorf("Unsubscribe left error %v\n", sub1.Err())
+		}
+	default:
+		t.Errorf("Unsubscribe didn't end the subscription\n")
+	}
+
+	// Cancelling the context also ends a subscription.
+	ctx, cancel := context.WithCancel(context.Background())
+	sub3, err := sc.Subscribe(ctx, &EventFilter{Topics: [][]string{{testTransferTopic}}}, func(ev *Event) {})
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v\n", err)
+	}
+	cancel()
+	select {
+	case <-sub3.Done():
+	case <-time.After(2 * time.Second):
+		t.Errorf("Cancelling the context didn't end the subscription\n")
+	}
+
+	handler := func(ev *Event) {}
+	bad := []struct {
+		filter  *EventFilter
+		handler EventHandler
+		channel bool // Subscribe_channel with a nil channel instead of Subscribe
+	}{
+		{&EventFilter{Event: "Approval"}, handler, false},
+		{&EventFilter{Event: "Transfer", Params: map[string]interface{}{"value": 1}}, handler, false},
+		{&EventFilter{Params: map[string]interface{}{"to": testToA}}, handler, false},
+		{&EventFilter{Event: "Transfer", Topics: [][]string{{testTransferTopic}}}, handler, false},
+		{nil, handler, false},
+		{&EventFilter{Event: "Transfer"}, nil, false},
+		{&EventFilter{Event: "Transfer"}, nil, true},
+	}
+	for _, b := range bad {
+		var err error
+		if b.channel {
+			_, err = sc.Subscribe_channel(context.Background(), b.filter, nil)
+		} else {
+			_, err = sc.Subscribe(context.Background(), b.filter, b.handler)
+		}
+		if err == nil {
+			t.Errorf("Subscribe should have refused filter %v with handler %v\n", b.filter, b.handler != nil)
+		} else if _, ok := err.(*EventFilterError); !ok {
+			t.Errorf("Subscribe returned %v for filter %v, expected an EventFilterError\n", err, b.filter)
+		}
+	}
+}
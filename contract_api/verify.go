@@ -0,0 +1,74 @@
+package contract_api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Check the code at the contract address against the artifact's runtime bytecode before the
+// contract is used. Set_contract_address_ctx then refuses an address holding different code, and
+// on a contract bound with Set_contract_address the first call that reads from the contract, be it
+// an invocation, a multi-call, a simulation, a log query, a subscription or a wait for an event,
+// makes the check. A contract deployed by this object is trusted.
+func (self *SolidityContract) Set_verify_code(verify bool) {
+	self.verifyCode = verify
+}
+
+// Verify the code at the contract address when verification is on and the address has not been
+// verified since it was set. Every method that reads from the contract address calls this first.
+func (self *SolidityContract) ensure_verified(ctx context.Context) error {
+	if self.verifyCode && !self.codeVerified {
+		return self.Verify_code(ctx)
+	}
+	return nil
+}
+
+// Compare the code deployed at the contract address with the artifact's runtime bytecode. The
+// compiler's metadata hash at the end of the code is ignored, because it changes with things that
+// don't affect behaviour such as comments and source paths. A CodeMismatchError is returned when the
+// code differs.
+func (self *SolidityContract) Verify_code(ctx context.Context) error {
+	self.logger.Debug("Entry", self.contractAddress)
+	err := error(nil)
+	onchain := ""
+
+	if self.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before verifying the contract code.\n")}
+	} else if self.compiledContract == nil || self.compiledContract.RuntimeCode == "" {
+		err = &LoadError{fmt.Sprintf("The artifact for contract %v has no runtime bytecode to verify %v against.", self.name, self.contractAddress)}
+	} else if onchain, err = self.client.Get_code(ctx, self.contractAddress, "latest"); err == nil {
+		expected, _ := link_code(self.compiledContract.RuntimeCode, self.libraries)
+		if strip_metadata(strings.ToLower(onchain)) != strip_metadata(strings.ToLower(expected)) {
+			err = &CodeMismatchError{fmt.Sprintf("The code at %v is not contract %v, found %v bytes of code that don't match the runtime bytecode.", self.contractAddress, self.name, (len(onchain)-2)/2)}
+		} else {
+			self.codeVerified = true
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", self.codeVerified)
+	return err
+}
+
+// Remove the CBOR encoded metadata that solc appends to the runtime bytecode. The last two bytes of
+// the code hold the length of the CBOR map that precedes them.
+func strip_metadata(code string) string {
+	code = strings.TrimPrefix(code, "0x")
+	if len(code) < 4 {
+		return code
+	}
+	cbor_len, err := strconv.ParseUint(code[len(code)-4:], 16, 16)
+	end := len(code) - 4 - int(cbor_len)*2
+	if err != nil || cbor_len == 0 || end < 0 {
+		return code
+	}
+	// A CBOR map with a handful of entries starts with 0xa1 to 0xa5.
+	if cbor := code[end:]; cbor[0] != 'a' || cbor[1] < '1' || cbor[1] > '5' {
+		return code
+	}
+	return code[:end]
+}
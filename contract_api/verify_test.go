@@ -0,0 +1,116 @@
+package contract_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+// Runtime code followed by solc 0.8 style metadata; a2 64 "ipfs" 58 22 <34 bytes> 64 "solc" 43 <3 bytes>, and its length.
+const testRuntimeBody = "6080604052348015600f57600080fd5b50"
+
+func testMetadata(fill string) string {
+	hash := ""
+	for i := 0; i < 34; i++ {
+		hash += fill
+	}
+	return "a264697066735822" + hash + "64736f6c6343000813" + "0033"
+}
+
+func TestVerifyCode(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{"eth_getCode": "0x" + testRuntimeBody + testMetadata("11")}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc, _ := SolidityContractFactoryWithOptions("some_contract", WithRPCURL(server.URL), WithVerifyCode(true))
+	sc.Set_skip_eventlistener()
+	sc.Set_compiled_contract(&ABI{Code: "0x6060", RuntimeCode: "0x" + testRuntimeBody + testMetadata("22")})
+
+	// Only the metadata differs.
+	if err := sc.Set_contract_address_ctx(context.Background(), "0xb37e8570f16682474894d435b207bb9a67dec3d9"); err != nil {
+		t.Errorf("Set_contract_address_ctx returned error: %v\n", err)
+	}
+
+	node.results["eth_getCode"] = "0x" + testRuntimeBody + "00" + testMetadata("11")
+	if err := sc.Set_contract_address_ctx(context.Background(), "0xb37e8570f16682474894d435b207bb9a67dec3d9"); err == nil {
+		t.Errorf("Set_contract_address_ctx should have refused different code\n")
+	} else if _, ok := err.(*CodeMismatchError); !ok {
+		t.Errorf("Set_contract_address_ctx returned %v, expected a CodeMismatchError\n", err)
+	} else if sc.Get_contract_address() != "" {
+		t.Errorf("Set_contract_address_ctx kept the address %v after a mismatch\n", sc.Get_contract_address())
+	}
+
+	// Without the ctx variant the check is made by the first invocation.
+	node.results["eth_getCode"] = "0x"
+	sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+	if _, err := sc.Invoke_method("get_owner", nil); err == nil {
+		t.Errorf("Invoke_method should have failed verification of an address without code\n")
+	} else if _, ok := err.(*CodeMismatchError); !ok {
+		t.Errorf("Invoke_method returned %v, expected a CodeMismatchError\n", err)
+	}
+
+	if stripped := strip_metadata("0x" + testRuntimeBody); stripped != testRuntimeBody {
+		t.Errorf("strip_metadata changed code without metadata to %v\n", stripped)
+	}
+}
+
+func TestVerifyBeforeReading(t *testing.T) {
+	node := &fakeNode{results: map[string]interface{}{
+		"eth_getCode":          "0x" + testRuntimeBody + "00",
+		"eth_blockNumber":      "0x10",
+		"web3_sha3":            "0x1234567890abcdef",
+		"eth_call":             "0x000000000000000000000000000000000000000000000000000000000000000a",
+		"eth_getLogs":          []interface{}{},
+		"eth_newFilter":        "0x1",
+		"eth_getFilterChanges": []interface{}{},
+	}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	sc, _ := SolidityContractFactoryWithOptions("some_contract", WithRPCURL(server.URL), WithVerifyCode(true))
+	sc.Set_skip_eventlistener()
+	if err := json.Unmarshal([]byte(testCCJSONString), &sc.compiledContract); err != nil {
+		t.Fatalf("Error Unmarshalling test JSON, error: %v\n", err)
+	}
+	sc.compiledContract.RuntimeCode = "0x" + testRuntimeBody
+	sc.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+	ctx := context.Background()
+
+	// Nothing is read from an address holding other code, whichever way it is read.
+	if err := sc.Invoke_constant_methods_ctx(ctx, []*MethodCall{{Method: "get_container_id"}}); !is_code_mismatch(err) {
+		t.Errorf("Invoke_constant_methods_ctx returned %v, expected a CodeMismatchError\n", err)
+	}
+	if _, err := sc.Simulate_method(ctx, "exec_complete", nil); !is_code_mismatch(err) {
+		t.Errorf("Simulate_method returned %v, expected a CodeMismatchError\n", err)
+	}
+	if err := sc.Query_logs(ctx, &LogQuery{FromBlock: 1, ToBlock: 2}, func(ev *Event) {}); !is_code_mismatch(err) {
+		t.Errorf("Query_logs returned %v, expected a CodeMismatchError\n", err)
+	}
+	if _, err := sc.Subscribe(ctx, &EventFilter{}, func(ev *Event) {}); !is_code_mismatch(err) {
+		t.Errorf("Subscribe returned %v, expected a CodeMismatchError\n", err)
+	}
+
+	// Nor are its events waited for.
+	waiter, _ := SolidityContractFactoryWithOptions("some_contract", WithRPCURL(server.URL), WithVerifyCode(true))
+	waiter.compiledContract = sc.compiledContract
+	waiter.Set_contract_address("0xb37e8570f16682474894d435b207bb9a67dec3d9")
+	if _, err := waiter.Wait_for_event_ctx(ctx, []uint64{0}, "0x00000000000000000000000000000000000000aa"); !is_code_mismatch(err) {
+		t.Errorf("Wait_for_event_ctx returned %v, expected a CodeMismatchError\n", err)
+	}
+	if node.last_params("eth_call") != nil || node.last_params("eth_getLogs") != nil || node.last_params("eth_getFilterChanges") != nil {
+		t.Errorf("The unverified contract was read from\n")
+	}
+
+	// Once the code matches, the contract is read from.
+	node.results["eth_getCode"] = "0x" + testRuntimeBody
+	calls := []*MethodCall{{Method: "get_container_id"}}
+	if err := sc.Invoke_constant_methods_ctx(ctx, calls); err != nil || calls[0].Result != uint64(10) {
+		t.Errorf("Invoke_constant_methods_ctx returned %v %v, expected 10\n", calls[0].Result, err)
+	}
+}
+
+func is_code_mismatch(err error) bool {
+	_, ok := err.(*CodeMismatchError)
+	return ok
+}
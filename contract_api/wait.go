@@ -0,0 +1,82 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Decides whether an event is the one a wait is for.
+type EventPredicate func(ev *Event) bool
+
+// Stops the search of past logs once a wait has found its event.
+var errEventFound = errors.New("event found")
+
+// Wait for an event of the contract that matches the filter and the predicate, a nil predicate
+// accepting every event the filter matches, and return it. The wait gives up when the context is
+// cancelled or its deadline expires. With a from_block other than 0, the logs from that block on
+// are searched first, so that an event emitted before the call, for example by a transaction sent
+// just before it, is not missed; with 0 only events emitted after the call are seen. Retractions
+// are never returned, and with Confirmations in the filter an event is only returned once it has
+// that many confirmations.
+func (self *SolidityContract) Wait_for_event_matching(ctx context.Context, filter *EventFilter, from_block uint64, predicate EventPredicate) (*Event, error) {
+	self.logger.Debug("Entry", filter)
+	err := error(nil)
+	var event *abiDefEntry
+	var topics [][]string
+	var follower *log_follower
+	var found *Event
+
+	match := func(logs []Log) {
+		for ix := range logs {
+			if found == nil && !logs[ix].Removed && match_topics(topics, logs[ix].Topics) {
+				if ev := self.decode_event(event, &logs[ix]); predicate == nil || predicate(ev) {
+					found = ev
+				}
+			}
+		}
+	}
+
+	if filter == nil {
+		err = &EventFilterError{fmt.Sprintf("Unable to wait for events of contract %v without a filter.", self.name)}
+	} else if self.contractAddress == "" {
+		err = &RPCError{msg: fmt.Sprintf("This object has no contract address. Please use Set_contract_address() before waiting for events.\n")}
+	} else if err = self.ensure_verified(ctx); err == nil {
+		event, topics, err = self.filter_topics(filter)
+	}
+
+	if err == nil {
+		query := FilterQuery{Address: self.contractAddress, Topics: topic_query(topics)}
+		if follower, err = start_log_follower(ctx, self.client, query, filter.Confirmations, self.logger); err == nil {
+			defer follower.stop(context.Background())
+
+			if from_block != 0 && from_block <= follower.block {
+				err = self.client.Get_logs_paged(ctx, &query, from_block, follower.block, 0, func(logs []Log) error {
+					if match(follower.add(ctx, logs)); found != nil {
+						return errEventFound
+					}
+					return nil
+				})
+				if err == errEventFound {
+					err = nil
+				}
+			}
+
+			for found == nil && err == nil {
+				var logs []Log
+				if logs, err = follower.poll(ctx); err == nil {
+					if match(logs); found == nil {
+						self.logger.Debug("Debug", fmt.Sprintf("Waiting for events on contract %v.", self.contractAddress))
+						err = sleep_ctx(ctx, self.pollInterval)
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		self.logger.Debug("Error", err.Error())
+	}
+	self.logger.Debug("Exit ", found)
+	return found, err
+}
@@ -0,0 +1,62 @@
+package contract_api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitForEventMatching(t *testing.T) {
+	transfer := func(block string, to string) map[string]interface{} {
+		return map[string]interface{}{"blockNumber": block, "blockHash": "0x" + block[2:], "logIndex": "0x0", "topics": []string{testTransferTopic, testTopic(testFrom), testTopic(to)}}
+	}
+	node := &fakeNode{results: map[string]interface{}{"eth_blockNumber": "0x20", "eth_newFilter": "0x1", "eth_uninstallFilter": true, "eth_getFilterChanges": []interface{}{}}}
+	node.funcs = map[string]func(params []interface{}) (interface{}, error){
+		"eth_getLogs": func(params []interface{}) (interface{}, error) {
+			return []interface{}{transfer("0x18", testToA), transfer("0x19", testToB)}, nil
+		},
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	sc := testEventContract(t, server.URL)
+	ctx := context.Background()
+	to := func(addr string) EventPredicate {
+		return func(ev *Event) bool { return ev.Indexed["to"] == addr }
+	}
+
+	// An event emitted before the call is found from the starting block.
+	ev, err := sc.Wait_for_event_matching(ctx, &EventFilter{Event: "Transfer"}, 0x18, to(testToB))
+	if err != nil {
+		t.Fatalf("Wait_for_event_matching returned error: %v\n", err)
+	} else if ev.Log.BlockNumber != "0x19" {
+		t.Errorf("Wait_for_event_matching returned the event of block %v, expected 0x19\n", ev.Log.BlockNumber)
+	}
+	q := node.last_params("eth_getLogs").([]interface{})[0].(map[string]interface{})
+	if q["fromBlock"] != "0x18" || q["toBlock"] != "0x20" {
+		t.Errorf("Wait_for_event_matching searched blocks %v to %v, expected 0x18 to 0x20\n", q["fromBlock"], q["toBlock"])
+	}
+
+	if _, err := sc.Wait_for_event_matching(ctx, nil, 0x18, nil); err == nil {
+		t.Errorf("Wait_for_event_matching should have refused a nil filter\n")
+	} else if _, ok := err.(*EventFilterError); !ok {
+		t.Errorf("Wait_for_event_matching returned %v for a nil filter, expected an EventFilterError\n", err)
+	}
+
+	// Without a starting block, only events arriving through the filter are seen.
+	node.queue("eth_getFilterChanges", []interface{}{transfer("0x21", testToA)})
+	node.queue("eth_getFilterChanges", []interface{}{transfer("0x22", testToC), transfer("0x22", testToB)})
+	if ev, err = sc.Wait_for_event_matching(ctx, &EventFilter{Event: "Transfer"}, 0, to(testToC)); err != nil {
+		t.Fatalf("Wait_for_event_matching returned error: %v\n", err)
+	} else if ev.Log.BlockNumber != "0x22" || ev.Name != "Transfer" {
+		t.Errorf("Wait_for_event_matching returned %v from block %v, expected the transfer of block 0x22\n", ev.Name, ev.Log.BlockNumber)
+	}
+
+	// The wait gives up at the deadline.
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err = sc.Wait_for_event_matching(tctx, &EventFilter{Event: "Transfer"}, 0x18, to(testToC)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait_for_event_matching returned %v, expected %v\n", err, context.DeadlineExceeded)
+	}
+}
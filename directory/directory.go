@@ -0,0 +1,196 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+)
+
+// The address get_entry returns for a name and version that have no entry.
+const zero_address = "0x0000000000000000000000000000000000000000"
+
+// The event codes of the directory contract, the first indexed parameter of its events.
+type EventCode uint64
+
+const (
+	Add_entry_event EventCode = iota
+	Delete_entry_event
+)
+
+func (c EventCode) String() string {
+	switch c {
+	case Add_entry_event:
+		return "AddEntry"
+	case Delete_entry_event:
+		return "DeleteEntry"
+	}
+	return fmt.Sprintf("EventCode(%d)", uint64(c))
+}
+
+// The ways a directory operation can fail. Use errors.Is to test an error returned by this package
+// against one of these, e.g. errors.Is(err, ErrNotFound).
+var (
+	ErrNotFound          = errors.New("entry not found")
+	ErrInvalidEntry      = errors.New("invalid entry")
+	ErrAlreadyRegistered = errors.New("entry already registered")
+	ErrNotOwner          = errors.New("not the owner of the entry")
+)
+
+// An error from a directory operation. Kind is one of the Err sentinels above, or nil when the
+// contract or the node failed in some other way.
+type DirectoryError struct {
+	msg  string
+	Kind error
+}
+
+func (e *DirectoryError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+// Allow errors.Is to match the error against its kind.
+func (e *DirectoryError) Is(target error) bool {
+	return e != nil && e.Kind != nil && e.Kind == target
+}
+
+// A typed client for the directory contract, contracts/directory.sol, which maps a name and a
+// version to the address of a contract and the account that registered it. The contract is used as
+// given, so it must already be loaded and have its address set.
+type Directory struct {
+	contract *contract_api.SolidityContract
+}
+
+func DirectoryFactory(contract *contract_api.SolidityContract) *Directory {
+	return &Directory{contract: contract}
+}
+
+func (self *Directory) Get_contract() *contract_api.SolidityContract {
+	return self.contract
+}
+
+// Return the address of the contract registered under the name and version. A name and version
+// with no entry is reported as ErrNotFound.
+func (self *Directory) Lookup(ctx context.Context, name string, version int) (string, error) {
+	res, err := self.contract.Invoke_method_ctx(ctx, "get_entry_by_version", []interface{}{name, version})
+	if err != nil {
+		return "", err
+	} else if addr, ok := res.(string); !ok {
+		return "", &DirectoryError{msg: fmt.Sprintf("get_entry_by_version returned %v for %v version %v, expected an address.", res, name, version)}
+	} else if addr == zero_address {
+		return "", &DirectoryError{msg: fmt.Sprintf("There is no entry for %v version %v.", name, version), Kind: ErrNotFound}
+	} else {
+		return addr, nil
+	}
+}
+
+// Register the contract address under the name and version, owned by the account the contract
+// sends transactions from. The registration is tried with eth_call first, so that a refusal is
+// reported as ErrInvalidEntry or ErrAlreadyRegistered instead of a transaction that does nothing.
+func (self *Directory) Register(ctx context.Context, name string, address string, version int) error {
+	params := []interface{}{name, address, version}
+	res, err := self.contract.Simulate_method(ctx, "add_entry", params)
+	if err == nil {
+		err = add_entry_error(res, name, version)
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "add_entry", params)
+	}
+	return err
+}
+
+// Remove the entry for the name and version. Only the account that registered an entry can remove
+// it. The removal is tried with eth_call first, so that a refusal is reported as ErrNotFound or
+// ErrNotOwner instead of a transaction that does nothing.
+func (self *Directory) Unregister(ctx context.Context, name string, version int) error {
+	params := []interface{}{name, version}
+	res, err := self.contract.Simulate_method(ctx, "delete_entry", params)
+	if err == nil {
+		if deleted, ok := res.(bool); !ok {
+			err = &DirectoryError{msg: fmt.Sprintf("delete_entry returned %v for %v version %v, expected a bool.", res, name, version)}
+		} else if !deleted {
+			if _, err = self.Lookup(ctx, name, version); err == nil {
+				err = &DirectoryError{msg: fmt.Sprintf("The entry for %v version %v is owned by another account.", name, version), Kind: ErrNotOwner}
+			}
+		}
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "delete_entry", params)
+	}
+	return err
+}
+
+// Map the return code of add_entry to an error.
+func add_entry_error(res interface{}, name string, version int) error {
+	code, ok := res.(uint64)
+	switch {
+	case !ok:
+		return &DirectoryError{msg: fmt.Sprintf("add_entry returned %v for %v version %v, expected a return code.", res, name, version)}
+	case code == 0:
+		return nil
+	case code == 1:
+		return &DirectoryError{msg: fmt.Sprintf("Unable to register %v version %v, the name is empty or the address is zero.", name, version), Kind: ErrInvalidEntry}
+	case code == 2:
+		return &DirectoryError{msg: fmt.Sprintf("Unable to register %v version %v, it is already registered.", name, version), Kind: ErrAlreadyRegistered}
+	}
+	return &DirectoryError{msg: fmt.Sprintf("add_entry returned unknown code %v for %v version %v.", code, name, version)}
+}
+
+// A change to the directory, decoded from an AddEntry or DeleteEntry event. Owner is the account
+// that added or deleted the entry. Removed is set when a chain reorganisation undid the change.
+type EntryEvent struct {
+	Code     EventCode
+	Name     string
+	Version  uint64
+	Contract string
+	Owner    string
+	Removed  bool
+	Log      contract_api.Log
+}
+
+// Both events are anonymous and have the same parameters, so a filter on either matches both; the
+// event code tells them apart.
+var entry_filter = contract_api.EventFilter{Event: "AddEntry"}
+
+// Decode an event delivered for the directory contract, such as by Subscribe or Query_logs. The log
+// is decoded from its topics, so it needn't have been matched by a filter naming the event.
+func (self *Directory) Decode_event(ev *contract_api.Event) (*EntryEvent, error) {
+	if len(ev.Log.Topics) != 4 {
+		return nil, &DirectoryError{msg: fmt.Sprintf("Unable to decode directory event from log %v in block %v, it has %v topics.", ev.Log.LogIndex, ev.Log.BlockNumber, len(ev.Log.Topics))}
+	}
+	code := EventCode(contract_api.Hex_to_uint64(ev.Log.Topics[0]))
+	if code != Add_entry_event && code != Delete_entry_event {
+		return nil, &DirectoryError{msg: fmt.Sprintf("Unknown directory event code %v in block %v.", uint64(code), ev.Log.BlockNumber)}
+	}
+
+	full, err := self.contract.Decode_event_as(code.String(), &ev.Log)
+	if err != nil {
+		return nil, err
+	}
+	version, ok1 := full.Indexed["version"].(uint64)
+	contract, ok2 := full.Indexed["_contract"].(string)
+	name, ok3 := full.Data["_name"].(string)
+	owner, ok4 := full.Indexed["_adder"].(string)
+	if !ok4 {
+		owner, ok4 = full.Indexed["_deleter"].(string)
+	}
+	if !(ok1 && ok2 && ok3 && ok4) {
+		return nil, &DirectoryError{msg: fmt.Sprintf("Unable to decode directory event from log %v in block %v.", ev.Log.LogIndex, ev.Log.BlockNumber)}
+	}
+	return &EntryEvent{Code: code, Name: name, Version: version, Contract: contract, Owner: owner, Removed: ev.Removed, Log: ev.Log}, nil
+}
+
+// Call the handler with each change to the directory, until the context is cancelled or the
+// subscription is ended. Logs that can't be decoded are skipped.
+func (self *Directory) Subscribe(ctx context.Context, handler func(ev *EntryEvent)) (*contract_api.Subscription, error) {
+	filter := entry_filter
+	return self.contract.Subscribe(ctx, &filter, func(ev *contract_api.Event) {
+		if entry, err := self.Decode_event(ev); err == nil {
+			handler(entry)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+	"github.com/open-horizon/go-solidity/contract_api/contracttest"
+)
+
+const (
+	testDirectory = "0xb37e8570f16682474894d435b207bb9a67dec3d9"
+	testOwner     = "0x1111111111111111111111111111111111111111"
+	testAddress   = "0x00000000000000000000000000000000000000aa"
+)
+
+// The results of the node for the calls that aren't about the directory contract itself.
+var testResults = map[string]interface{}{
+	"eth_blockNumber":      "0x10",
+	"eth_newFilter":        "0x1",
+	"eth_uninstallFilter":  true,
+	"eth_getFilterChanges": []interface{}{},
+}
+
+func testDirectoryClient(t *testing.T, node http.Handler) (*Directory, *httptest.Server) {
+	server := httptest.NewServer(node)
+	sc, _ := contract_api.SolidityContractFactoryWithOptions("directory", contract_api.WithPollInterval(10*time.Millisecond))
+	if err := sc.Load_artifact_file("../contracts/directory.json"); err != nil {
+		t.Fatalf("Unable to load the directory contract, error: %v\n", err)
+	}
+	sc.Set_rpcurl(server.URL)
+	sc.Set_from(testOwner)
+	sc.Set_skip_eventlistener()
+	sc.Set_contract_address(testDirectory)
+	return DirectoryFactory(sc), server
+}
+
+func TestDirectory(t *testing.T) {
+	node := &contracttest.Node{Results: testResults, Outputs: map[string]string{
+		"get_entry_by_version(string,uint256)": "0x" + contracttest.Word(testAddress[2:]),
+		"add_entry(string,address,uint256)":    "0x" + contracttest.Word("2"),
+		"delete_entry(string,uint256)":         "0x" + contracttest.Word("0"),
+	}}
+	dir, server := testDirectoryClient(t, node)
+	defer server.Close()
+	ctx := context.Background()
+
+	if addr, err := dir.Lookup(ctx, "a", 0); err != nil || addr != testAddress {
+		t.Errorf("Lookup returned %v %v, expected %v\n", addr, err, testAddress)
+	}
+
+	// Refusals are found by eth_call and never sent as transactions.
+	if err := dir.Register(ctx, "a", testAddress, 0); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("Register returned %v, expected %v\n", err, ErrAlreadyRegistered)
+	}
+	node.Outputs["add_entry(string,address,uint256)"] = "0x" + contracttest.Word("1")
+	if err := dir.Register(ctx, "", testAddress, 0); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Register returned %v, expected %v\n", err, ErrInvalidEntry)
+	}
+	if err := dir.Unregister(ctx, "a", 0); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Unregister returned %v, expected %v\n", err, ErrNotOwner)
+	}
+
+	node.Outputs["get_entry_by_version(string,uint256)"] = "0x" + contracttest.Word("0")
+	if _, err := dir.Lookup(ctx, "b", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup returned %v, expected %v\n", err, ErrNotFound)
+	}
+	if err := dir.Unregister(ctx, "b", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unregister returned %v, expected %v\n", err, ErrNotFound)
+	}
+	if n := node.Calls("eth_sendTransaction"); n != 0 {
+		t.Errorf("%v refused operations were sent as transactions\n", n)
+	}
+}
+
+func TestDirectoryEvents(t *testing.T) {
+	name := "0x" + contracttest.Word("20") + contracttest.Word("1") + "61" + strings.Repeat("0", 62)
+	node := &contracttest.Node{Results: testResults, Logs: []interface{}{
+		map[string]interface{}{"blockNumber": "0x5", "logIndex": "0x0", "data": name, "topics": []string{"0x" + contracttest.Word("0"), "0x" + contracttest.Word(testOwner[2:]), "0x" + contracttest.Word("3"), "0x" + contracttest.Word(testAddress[2:])}},
+		map[string]interface{}{"blockNumber": "0x6", "logIndex": "0x0", "data": name, "topics": []string{"0x" + contracttest.Word("1"), "0x" + contracttest.Word(testOwner[2:]), "0x" + contracttest.Word("3"), "0x" + contracttest.Word(testAddress[2:])}},
+	}}
+	dir, server := testDirectoryClient(t, node)
+	defer server.Close()
+
+	// A log matched by a bare filter, that doesn't name the event, decodes the same.
+	for _, filter := range []contract_api.EventFilter{entry_filter, {}} {
+		events := make([]*EntryEvent, 0, 2)
+		err := dir.Get_contract().Query_logs(context.Background(), &contract_api.LogQuery{Filter: filter, FromBlock: 1, ToBlock: 0x10}, func(ev *contract_api.Event) {
+			if entry, err := dir.Decode_event(ev); err != nil {
+				t.Errorf("Decode_event returned error: %v\n", err)
+			} else {
+				events = append(events, entry)
+			}
+		})
+		if err != nil {
+			t.Fatalf("Query_logs returned error: %v\n", err)
+		} else if len(events) != 2 {
+			t.Fatalf("Decoded %v events, expected 2\n", len(events))
+		}
+		for ix, code := range []EventCode{Add_entry_event, Delete_entry_event} {
+			if ev := events[ix]; ev.Code != code || ev.Name != "a" || ev.Version != 3 || ev.Contract != testAddress || ev.Owner != testOwner {
+				t.Errorf("Event %v decoded as %v with filter %v, expected %v of a version 3 at %v by %v\n", ix, *ev, filter.Event, code, testAddress, testOwner)
+			}
+		}
+	}
+}
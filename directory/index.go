@@ -0,0 +1,275 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+)
+
+// A registration of a contract address under a name and version, rebuilt from the events of the
+// directory contract. Added is the log of its AddEntry event. Once the entry is removed, Deleted is
+// the log of its DeleteEntry event and DeletedBy the account that removed it; until then Deleted is
+// nil.
+type Entry struct {
+	Name      string
+	Version   uint64
+	Contract  string
+	Owner     string
+	Added     contract_api.Log
+	Deleted   *contract_api.Log
+	DeletedBy string
+}
+
+// The name and version of an entry.
+type entry_key struct {
+	name    string
+	version uint64
+}
+
+// The state of the directory, rebuilt by replaying the events of the contract, since the contract
+// itself has no way to list its names. Every entry ever registered is kept, removed ones included,
+// so the index also holds the history of each name and version. It is safe to use from several
+// goroutines.
+type Index struct {
+	lock    sync.RWMutex
+	entries map[entry_key][]*Entry // The entries of each name and version, oldest first
+	block   uint64
+	ready   bool          // Set once the past events have been replayed
+	pending []*EntryEvent // The live events that arrived while the past events were replayed
+}
+
+func IndexFactory() *Index {
+	return &Index{entries: make(map[entry_key][]*Entry), ready: true}
+}
+
+// Return an index of the directory as it was at to_block, replaying the events from from_block on.
+// A from_block of 0 replays the whole history of the contract, and a to_block of 0 means the stable
+// block, as in a LogQuery. The index's block is to_block, or the stable block it was resolved to.
+func (self *Directory) Snapshot(ctx context.Context, from_block uint64, to_block uint64) (*Index, error) {
+	index := IndexFactory()
+	err := error(nil)
+	if to_block == 0 {
+		to_block, err = self.contract.Get_stable_block_number(ctx, entry_filter.Confirmations)
+	}
+	if err == nil {
+		err = self.replay(ctx, index, from_block, to_block)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Return an index of the directory that is kept up to date, replaying the events from from_block
+// on and then following new ones until the context is cancelled or the returned subscription is
+// ended. Changes undone by a chain reorganisation are undone in the index as well.
+func (self *Directory) Follow(ctx context.Context, from_block uint64) (*Index, *contract_api.Subscription, error) {
+	index := IndexFactory()
+	index.ready = false
+
+	// The subscription is made first so that no event falls between the replay and the
+	// subscription. Events seen by both are only applied once.
+	sub, err := self.Subscribe(ctx, index.follow)
+	if err != nil {
+		return nil, nil, err
+	}
+	current := uint64(0)
+	if current, err = self.contract.Get_client().Block_number(ctx); err == nil {
+		err = self.replay(ctx, index, from_block, current)
+	}
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, nil, err
+	}
+
+	index.lock.Lock()
+	for _, ev := range index.pending {
+		index.apply(ev)
+	}
+	index.pending, index.ready = nil, true
+	index.lock.Unlock()
+	return index, sub, nil
+}
+
+// Apply the past events of the contract in the block range to the index.
+func (self *Directory) replay(ctx context.Context, index *Index, from_block uint64, to_block uint64) error {
+	query := &contract_api.LogQuery{Filter: entry_filter, FromBlock: from_block, ToBlock: to_block}
+	err := self.contract.Query_logs(ctx, query, func(ev *contract_api.Event) {
+		if entry, err := self.Decode_event(ev); err == nil {
+			index.Apply(entry)
+		}
+	})
+	if err == nil {
+		index.lock.Lock()
+		if to_block > index.block {
+			index.block = to_block
+		}
+		index.lock.Unlock()
+	}
+	return err
+}
+
+// Hold the live events back until the past events have been replayed.
+func (self *Index) follow(ev *EntryEvent) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.ready {
+		self.apply(ev)
+	} else {
+		self.pending = append(self.pending, ev)
+	}
+}
+
+// Apply a change to the directory to the index. An event the index has already seen is ignored,
+// and a retraction undoes the change it retracts.
+func (self *Index) Apply(ev *EntryEvent) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.apply(ev)
+}
+
+func (self *Index) apply(ev *EntryEvent) {
+	key := entry_key{name: ev.Name, version: ev.Version}
+	history := self.entries[key]
+
+	switch {
+	case ev.Code == Add_entry_event && !ev.Removed:
+		for _, e := range history {
+			if same_log(&e.Added, &ev.Log) {
+				return
+			}
+		}
+		self.entries[key] = append(history, &Entry{Name: ev.Name, Version: ev.Version, Contract: ev.Contract, Owner: ev.Owner, Added: ev.Log})
+	case ev.Code == Add_entry_event:
+		for ix, e := range history {
+			if same_log(&e.Added, &ev.Log) {
+				history = append(history[:ix], history[ix+1:]...)
+				break
+			}
+		}
+		if len(history) == 0 {
+			delete(self.entries, key)
+		} else {
+			self.entries[key] = history
+		}
+	case !ev.Removed:
+		for ix := len(history) - 1; ix >= 0; ix-- {
+			if e := history[ix]; e.Deleted != nil && same_log(e.Deleted, &ev.Log) {
+				return
+			} else if e.Deleted == nil && e.Contract == ev.Contract {
+				deleted := ev.Log
+				e.Deleted, e.DeletedBy = &deleted, ev.Owner
+				break
+			}
+		}
+	default:
+		for _, e := range history {
+			if e.Deleted != nil && same_log(e.Deleted, &ev.Log) {
+				e.Deleted, e.DeletedBy = nil, ""
+			}
+		}
+	}
+
+	if block := contract_api.Hex_to_uint64(ev.Log.BlockNumber); !ev.Removed && block > self.block {
+		self.block = block
+	}
+}
+
+// Whether two logs are the same log on the chain.
+func same_log(a *contract_api.Log, b *contract_api.Log) bool {
+	return a.BlockNumber == b.BlockNumber && a.BlockHash == b.BlockHash && a.TransactionHash == b.TransactionHash && a.LogIndex == b.LogIndex
+}
+
+// Return the newest block the index has seen the events of. For a snapshot to a given block, that
+// is the block.
+func (self *Index) Get_block() uint64 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.block
+}
+
+// Return the entry registered under the name and version, or ErrNotFound when there is none.
+func (self *Index) Get(name string, version uint64) (*Entry, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	history := self.entries[entry_key{name: name, version: version}]
+	if len(history) != 0 && history[len(history)-1].Deleted == nil {
+		e := *history[len(history)-1]
+		return &e, nil
+	}
+	return nil, &DirectoryError{msg: fmt.Sprintf("There is no entry for %v version %v in the index.", name, version), Kind: ErrNotFound}
+}
+
+// Return the names that have an entry registered, in order.
+func (self *Index) Names() []string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	seen := make(map[string]bool)
+	for key, history := range self.entries {
+		if history[len(history)-1].Deleted == nil {
+			seen[key.name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return the versions registered under the name, in order.
+func (self *Index) Versions(name string) []uint64 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	versions := make([]uint64, 0, 2)
+	for key, history := range self.entries {
+		if key.name == name && history[len(history)-1].Deleted == nil {
+			versions = append(versions, key.version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Return the registered entries, by name and then version. With deleted, the removed entries are
+// returned as well, each name and version in the order its entries were registered.
+func (self *Index) Entries(deleted bool) []*Entry {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	keys := make([]entry_key, 0, len(self.entries))
+	for key := range self.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].name < keys[j].name || keys[i].name == keys[j].name && keys[i].version < keys[j].version
+	})
+
+	res := make([]*Entry, 0, len(keys))
+	for _, key := range keys {
+		for _, e := range self.entries[key] {
+			if deleted || e.Deleted == nil {
+				c := *e
+				res = append(res, &c)
+			}
+		}
+	}
+	return res
+}
+
+// Return every entry ever registered under the name and version, oldest first, removed ones
+// included.
+func (self *Index) History(name string, version uint64) []*Entry {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	history := self.entries[entry_key{name: name, version: version}]
+	res := make([]*Entry, 0, len(history))
+	for _, e := range history {
+		c := *e
+		res = append(res, &c)
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package directory
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-horizon/go-solidity/contract_api/contracttest"
+)
+
+const testOther = "0x00000000000000000000000000000000000000bb"
+
+// The log of an AddEntry or DeleteEntry event.
+func entry_log(block uint64, code EventCode, name string, version uint64, contract string) map[string]interface{} {
+	h := hex.EncodeToString([]byte(name))
+	data := "0x" + contracttest.Word("20") + contracttest.Word(fmt.Sprintf("%x", len(name))) + h + strings.Repeat("0", (64-len(h)%64)%64)
+	topics := []string{"0x" + contracttest.Word(fmt.Sprintf("%x", uint64(code))), "0x" + contracttest.Word(testOwner[2:]), "0x" + contracttest.Word(fmt.Sprintf("%x", version)), "0x" + contracttest.Word(contract[2:])}
+	return map[string]interface{}{"blockNumber": fmt.Sprintf("0x%x", block), "logIndex": "0x0", "data": data, "topics": topics}
+}
+
+func TestIndexSnapshot(t *testing.T) {
+	node := &contracttest.Node{Results: testResults, Logs: []interface{}{
+		entry_log(2, Add_entry_event, "a", 0, testAddress),
+		entry_log(3, Add_entry_event, "a", 1, testAddress),
+		entry_log(4, Add_entry_event, "b", 0, testAddress),
+		entry_log(5, Delete_entry_event, "a", 0, testAddress),
+		entry_log(6, Add_entry_event, "a", 0, testOther),
+		entry_log(7, Delete_entry_event, "b", 0, testAddress),
+	}}
+	dir, server := testDirectoryClient(t, node)
+	defer server.Close()
+
+	index, err := dir.Snapshot(context.Background(), 1, 0x10)
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v\n", err)
+	}
+	if names := index.Names(); fmt.Sprint(names) != "[a]" {
+		t.Errorf("Names returned %v, expected [a]\n", names)
+	}
+	if versions := index.Versions("a"); fmt.Sprint(versions) != "[0 1]" {
+		t.Errorf("Versions returned %v, expected [0 1]\n", versions)
+	}
+	if e, err := index.Get("a", 0); err != nil || e.Contract != testOther || e.Owner != testOwner {
+		t.Errorf("Get returned %v %v, expected the entry for %v\n", e, err, testOther)
+	}
+	if _, err := index.Get("b", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted entry returned %v, expected %v\n", err, ErrNotFound)
+	}
+	if history := index.History("a", 0); len(history) != 2 || history[0].Deleted == nil || history[0].Deleted.BlockNumber != "0x5" || history[0].DeletedBy != testOwner || history[1].Deleted != nil {
+		t.Errorf("History returned %v entries, expected the deleted entry and its replacement\n", len(history))
+	}
+	if n := len(index.Entries(true)); n != 4 {
+		t.Errorf("Entries returned %v entries with the deleted ones, expected 4\n", n)
+	}
+	if n := len(index.Entries(false)); n != 2 {
+		t.Errorf("Entries returned %v entries, expected 2\n", n)
+	}
+	if block := index.Get_block(); block != 0x10 {
+		t.Errorf("Get_block returned %v, expected 16\n", block)
+	}
+
+	// A snapshot up to the stable block is as of that block, not of the newest event.
+	if index, err = dir.Snapshot(context.Background(), 1, 0); err != nil {
+		t.Fatalf("Snapshot to the stable block returned error: %v\n", err)
+	} else if block := index.Get_block(); block != 0x10 {
+		t.Errorf("Get_block returned %v for a snapshot to the stable block, expected 16\n", block)
+	}
+}
+
+func TestIndexFollow(t *testing.T) {
+	node := &contracttest.Node{Results: testResults, Logs: []interface{}{entry_log(5, Add_entry_event, "a", 0, testAddress)}}
+	dir, server := testDirectoryClient(t, node)
+	defer server.Close()
+
+	index, sub, err := dir.Follow(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Follow returned error: %v\n", err)
+	}
+	defer sub.Unsubscribe()
+	wait := func(expected string) {
+		for deadline := time.Now().Add(5 * time.Second); fmt.Sprint(index.Names()) != expected && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if names := index.Names(); fmt.Sprint(names) != expected {
+			t.Fatalf("Names returned %v, expected %v\n", names, expected)
+		}
+	}
+	wait("[a]")
+
+	// A log seen by both the replay and the subscription is only applied once.
+	added := entry_log(0x11, Add_entry_event, "b", 2, testOther)
+	node.Queue("eth_getFilterChanges", []interface{}{entry_log(5, Add_entry_event, "a", 0, testAddress), added})
+	wait("[a b]")
+	if history := index.History("a", 0); len(history) != 1 {
+		t.Errorf("History returned %v entries, expected 1\n", len(history))
+	}
+	if block := index.Get_block(); block != 0x11 {
+		t.Errorf("Get_block returned %v, expected 17\n", block)
+	}
+
+	// A reorganisation undoes the registration.
+	removed := entry_log(0x11, Add_entry_event, "b", 2, testOther)
+	removed["removed"] = true
+	node.Queue("eth_getFilterChanges", []interface{}{removed})
+	wait("[a]")
+	if history := index.History("b", 2); len(history) != 0 {
+		t.Errorf("History returned %v entries for a retracted registration, expected none\n", len(history))
+	}
+}
@@ -0,0 +1,294 @@
+package metering
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+	"golang.org/x/crypto/sha3"
+)
+
+// The event codes of the metering contract, the first indexed parameter of its events. Each code
+// has its own event.
+type EventCode uint64
+
+const (
+	Created EventCode = iota
+	Created_detail
+	Create_fraud_alert
+	Admin_deleted
+)
+
+var event_names = []string{"CreatedMeter", "CreatedMeterDetail", "CreateFraudAlert", "AdminDeleted"}
+
+// Return the name of the event emitted with the code.
+func (c EventCode) String() string {
+	if uint64(c) < uint64(len(event_names)) {
+		return event_names[c]
+	}
+	return fmt.Sprintf("EventCode(%d)", uint64(c))
+}
+
+// The ways a metering operation can fail. Use errors.Is to test an error returned by this package
+// against one of these, e.g. errors.Is(err, ErrStaleReading).
+var (
+	ErrBadSignature   = errors.New("signature does not match")
+	ErrInvalidReading = errors.New("invalid meter reading")
+	ErrStaleReading   = errors.New("meter reading older than the recorded one")
+	ErrNoMeter        = errors.New("no such meter")
+	ErrNotAdmin       = errors.New("not the contract owner")
+)
+
+// An error from a metering operation. Kind is one of the Err sentinels above, or nil when the
+// contract or the node failed in some other way.
+type MeterError struct {
+	msg  string
+	Kind error
+}
+
+func (e *MeterError) Error() string {
+	if e != nil {
+		return e.msg
+	} else {
+		return ""
+	}
+}
+
+// Allow errors.Is to match the error against its kind.
+func (e *MeterError) Is(target error) bool {
+	return e != nil && e.Kind != nil && e.Kind == target
+}
+
+// Signs a hash, 0x prefixed hex, as an account, returning the 65 byte signature as 0x prefixed
+// hex. The signature must be over the hash with the "\x19Ethereum Signed Message:\n32" prefix, as
+// eth_sign makes it, so an EthClient is a Signer for the accounts of its node.
+type Signer interface {
+	Sign(ctx context.Context, account string, data string) (string, error)
+}
+
+// A meter reading of an agreement, by the producer, to be recorded in the metering contract. The
+// consumer authorises the reading by signing its meter hash, and both parties sign ContractHash,
+// the hash of the terms they agreed to. The contract checks all three signatures.
+type Reading struct {
+	Count            uint64
+	Time             uint64 // Seconds since the epoch
+	AgreementID      []byte
+	Consumer         string
+	ContractHash     []byte
+	ConsumerMeterSig []byte // The consumer's signature of the meter hash
+	ProducerSig      []byte // The producer's signature of the contract hash
+	ConsumerSig      []byte // The consumer's signature of the contract hash
+}
+
+// Return the canonical hash of a meter reading: the SHA3 FIPS-202 hash of the count and the time,
+// each a 32 byte big endian number, followed by the agreement ID as a bytes32.
+func Meter_hash(count uint64, time uint64, agreement_id []byte) []byte {
+	meter := make([]byte, 96)
+	put_uint256(meter[0:32], count)
+	put_uint256(meter[32:64], time)
+	copy(meter[64:96], agreement_id)
+	hash := sha3.Sum256(meter)
+	return hash[:]
+}
+
+func put_uint256(buf []byte, n uint64) {
+	for i := len(buf) - 1; n != 0; i-- {
+		buf[i] = byte(n & 0xff)
+		n >>= 8
+	}
+}
+
+func (self *Reading) Meter_hash() []byte {
+	return Meter_hash(self.Count, self.Time, self.AgreementID)
+}
+
+// A typed client for the metering contract, contracts/metering.sol, which records the final meter
+// reading of an agreement between a producer and a consumer. The account the contract sends
+// transactions from is the producer. The contract is used as given, so it must already be loaded
+// and have its address set.
+type Metering struct {
+	contract *contract_api.SolidityContract
+}
+
+func MeteringFactory(contract *contract_api.SolidityContract) *Metering {
+	return &Metering{contract: contract}
+}
+
+func (self *Metering) Get_contract() *contract_api.SolidityContract {
+	return self.contract
+}
+
+// Fill in the signatures the reading is missing: the consumer's signatures of the meter hash and
+// of the contract hash through the consumer's signer, and the producer's signature of the contract
+// hash through the producer's signer. A signer that isn't needed can be nil.
+func (self *Metering) Sign_reading(ctx context.Context, r *Reading, producer Signer, consumer Signer) error {
+	sigs := []struct {
+		sig     *[]byte
+		signer  Signer
+		account string
+		hash    []byte
+	}{
+		{&r.ConsumerMeterSig, consumer, r.Consumer, r.Meter_hash()},
+		{&r.ConsumerSig, consumer, r.Consumer, r.ContractHash},
+		{&r.ProducerSig, producer, self.contract.Get_from(), r.ContractHash},
+	}
+	for _, s := range sigs {
+		if len(*s.sig) != 0 {
+			continue
+		} else if s.signer == nil {
+			return &MeterError{msg: fmt.Sprintf("Unable to sign the reading of agreement %x as %v, there is no signer.", r.AgreementID, s.account)}
+		}
+		sig, err := s.signer.Sign(ctx, s.account, "0x"+hex.EncodeToString(s.hash))
+		if err != nil {
+			return err
+		} else if *s.sig, err = hex.DecodeString(strings.TrimPrefix(sig, "0x")); err != nil {
+			return &MeterError{msg: fmt.Sprintf("Signature %v by %v is not hex, error: %v", sig, s.account, err)}
+		}
+	}
+	return nil
+}
+
+// Record the reading, as the producer. The signatures are checked locally, the way the contract
+// checks them, and the reading is then tried with eth_call, so that a refusal is reported as an
+// error instead of a transaction that does nothing, or one that raises a fraud alert because a
+// signature doesn't match.
+func (self *Metering) Create(ctx context.Context, r *Reading) error {
+	sigs := []struct {
+		sig    []byte
+		signer string
+		hash   []byte
+	}{
+		{r.ConsumerMeterSig, r.Consumer, r.Meter_hash()},
+		{r.ProducerSig, self.contract.Get_from(), r.ContractHash},
+		{r.ConsumerSig, r.Consumer, r.ContractHash},
+	}
+	for _, s := range sigs {
+		if signer, err := contract_api.Recover_signer(s.hash, s.sig); err != nil {
+			return &MeterError{msg: fmt.Sprintf("Unable to record the reading of agreement %x, error: %v", r.AgreementID, err), Kind: ErrBadSignature}
+		} else if !strings.EqualFold(signer, s.signer) {
+			return &MeterError{msg: fmt.Sprintf("Unable to record the reading of agreement %x, hash %x is signed by %v instead of %v.", r.AgreementID, s.hash, signer, s.signer), Kind: ErrBadSignature}
+		}
+	}
+	params := []interface{}{r.Count, r.Time, r.AgreementID, r.Meter_hash(), r.ConsumerMeterSig, r.ContractHash, r.ProducerSig, r.ConsumerSig, r.Consumer}
+	res, err := self.contract.Simulate_method(ctx, "create_meter", params)
+	if err == nil {
+		err = return_code_error("create_meter", res, r.AgreementID, map[uint64]error{1: ErrBadSignature, 2: ErrInvalidReading, 3: ErrStaleReading})
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "create_meter", params)
+	}
+	return err
+}
+
+// Return the count and time of the reading recorded for the agreement with the counter party. Both
+// are 0 when no reading has been recorded.
+func (self *Metering) Read(ctx context.Context, counter_party string, agreement_id []byte) (uint64, uint64, error) {
+	res, err := self.contract.Invoke_method_ctx(ctx, "read_meter", []interface{}{agreement_id, counter_party})
+	if err != nil {
+		return 0, 0, err
+	}
+	if values, ok := res.([]interface{}); ok && len(values) == 2 {
+		count, ok1 := values[0].(uint64)
+		time, ok2 := values[1].(uint64)
+		if ok1 && ok2 {
+			return count, time, nil
+		}
+	}
+	return 0, 0, &MeterError{msg: fmt.Sprintf("read_meter returned %v for agreement %x, expected a count and a time.", res, agreement_id)}
+}
+
+// Delete the reading of any agreement, as the owner of the contract.
+func (self *Metering) Admin_delete(ctx context.Context, producer string, consumer string, agreement_id []byte) error {
+	params := []interface{}{producer, consumer, agreement_id}
+	res, err := self.contract.Simulate_method(ctx, "admin_delete_meter", params)
+	if err == nil {
+		err = return_code_error("admin_delete_meter", res, agreement_id, map[uint64]error{1: ErrNoMeter, 2: ErrNotAdmin})
+	}
+	if err == nil {
+		_, err = self.contract.Invoke_method_ctx(ctx, "admin_delete_meter", params)
+	}
+	return err
+}
+
+// Map the return code of a method to an error.
+func return_code_error(method string, res interface{}, agreement_id []byte, kinds map[uint64]error) error {
+	code, ok := res.(uint64)
+	if !ok {
+		return &MeterError{msg: fmt.Sprintf("%v returned %v for agreement %x, expected a return code.", method, res, agreement_id)}
+	} else if code == 0 {
+		return nil
+	} else if kind, ok := kinds[code]; ok {
+		return &MeterError{msg: fmt.Sprintf("%v refused the reading of agreement %x with code %v: %v.", method, agreement_id, code, kind), Kind: kind}
+	}
+	return &MeterError{msg: fmt.Sprintf("%v returned unknown code %v for agreement %x.", method, code, agreement_id)}
+}
+
+// Something that happened to a meter reading, decoded from one of the events of the contract. The
+// reading is only set for Created_detail and Create_fraud_alert. Removed is set when a chain
+// reorganisation undid it.
+type MeterEvent struct {
+	Code        EventCode
+	Producer    string
+	Consumer    string
+	AgreementID []byte
+	Reading     *Reading
+	MeterHash   []byte
+	Removed     bool
+	Log         contract_api.Log
+}
+
+// All the events are anonymous and share their indexed parameters, so a filter on one of them
+// matches all of them; the event code tells them apart.
+var meter_filter = contract_api.EventFilter{Event: "CreatedMeter"}
+
+// Decode an event delivered for the metering contract, such as by Subscribe or Query_logs.
+func (self *Metering) Decode_event(ev *contract_api.Event) (*MeterEvent, error) {
+	if len(ev.Log.Topics) != 4 {
+		return nil, &MeterError{msg: fmt.Sprintf("Unable to decode metering event from log %v in block %v, it has %v topics.", ev.Log.LogIndex, ev.Log.BlockNumber, len(ev.Log.Topics))}
+	}
+	code := EventCode(contract_api.Hex_to_uint64(ev.Log.Topics[0]))
+	if uint64(code) >= uint64(len(event_names)) {
+		return nil, &MeterError{msg: fmt.Sprintf("Unknown metering event code %v in block %v.", uint64(code), ev.Log.BlockNumber)}
+	}
+
+	full, err := self.contract.Decode_event_as(code.String(), &ev.Log)
+	if err != nil {
+		return nil, err
+	} else if full.Data == nil {
+		return nil, &MeterError{msg: fmt.Sprintf("Unable to decode the data of %v event from log %v in block %v.", code, ev.Log.LogIndex, ev.Log.BlockNumber)}
+	}
+	id, _ := hex.DecodeString(strings.TrimPrefix(ev.Log.Topics[3], "0x"))
+	res := &MeterEvent{Code: code, AgreementID: id, Removed: ev.Removed, Log: ev.Log}
+	res.Producer, _ = full.Indexed["_producer"].(string)
+	res.Consumer, _ = full.Indexed["_consumer"].(string)
+	if code == Created_detail || code == Create_fraud_alert {
+		r := &Reading{AgreementID: id, Consumer: res.Consumer}
+		r.Count, _ = full.Data["_count"].(uint64)
+		r.Time, _ = full.Data["_time"].(uint64)
+		r.ConsumerMeterSig, _ = full.Data["_consumerMeterSig"].([]byte)
+		r.ProducerSig, _ = full.Data["_producerSig"].([]byte)
+		r.ConsumerSig, _ = full.Data["_consumerSig"].([]byte)
+		if hash, ok := full.Data["_contractHash"].(string); ok {
+			r.ContractHash = []byte(hash)
+		}
+		if hash, ok := full.Data["_meterHash"].(string); ok {
+			res.MeterHash = []byte(hash)
+		}
+		res.Reading = r
+	}
+	return res, nil
+}
+
+// Call the handler with each event of the contract, until the context is cancelled or the
+// subscription is ended. Logs that can't be decoded are skipped.
+func (self *Metering) Subscribe(ctx context.Context, handler func(ev *MeterEvent)) (*contract_api.Subscription, error) {
+	filter := meter_filter
+	return self.contract.Subscribe(ctx, &filter, func(ev *contract_api.Event) {
+		if mev, err := self.Decode_event(ev); err == nil {
+			handler(mev)
+		}
+	})
+}
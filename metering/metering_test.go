@@ -0,0 +1,184 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-horizon/go-solidity/contract_api"
+	"github.com/open-horizon/go-solidity/contract_api/contracttest"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	testMetering = "0xb37e8570f16682474894d435b207bb9a67dec3d9"
+	testProducer = "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf" // The account with private key 1
+	testConsumer = "0x2b5ad5c4795c026514f8317c7a215e218dccd6cf" // The account with private key 2
+)
+
+var (
+	testID   = bytes.Repeat([]byte{0xab}, 32)
+	testHash = bytes.Repeat([]byte{0xcd}, 32)
+)
+
+// The results of the node for the calls that aren't about the contract itself.
+var testResults = map[string]interface{}{"eth_blockNumber": "0x10"}
+
+// The signatures of the reading of TestMetering, by the account and hash signed.
+var testSigs = map[string]string{
+	testConsumer + " 0x79bdda61d4bd6cceeed14e5d5770f94d5bff4d97ee3726dd14b6fd50e0dc4060": "0x28b7f3a019749cce6fc677afa8fae72ec10e811ed4b04e1963143cef87654b75749a920c3b8ab8154e5cd8027e1e8b62df1c941c44e58d8d2c40ced25b37992a1b",
+	testConsumer + " 0x" + strings.Repeat("cd", 32):                                      "0x71b357df56cfc77291b75e5f550d72687c1bef84ff9eadb4946eb55516a89d6fec1bd05a440496978827297f63867d1e36e84411cd8f8fb3b7bd7d2dd3ff51f61b",
+	testProducer + " 0x" + strings.Repeat("cd", 32):                                      "0x4cecb33a915e2c3b189eb6ee45fa4eb3eddeff09729945c600f893bd4381b294b32c8b35c330476894865830128bba5d9b95ab96ce9efdd8b74c48db71c35bce1c",
+}
+
+// Signs with the signatures in testSigs, making up a signature for anything else.
+type fakeSigner struct {
+	signed []string
+}
+
+func (s *fakeSigner) Sign(ctx context.Context, account string, data string) (string, error) {
+	s.signed = append(s.signed, account+" "+data)
+	if sig, ok := testSigs[account+" "+data]; ok {
+		return sig, nil
+	}
+	return "0x" + contracttest.Keccak([]byte(account+data)) + contracttest.Keccak([]byte(data+account)) + "1b", nil
+}
+
+func testMeteringClient(t *testing.T, node *contracttest.Node) (*Metering, *httptest.Server) {
+	server := httptest.NewServer(node)
+	sc := contract_api.SolidityContractFactory("metering")
+	if err := sc.Load_artifact_file("../contracts/metering.json"); err != nil {
+		t.Fatalf("Unable to load the metering contract, error: %v\n", err)
+	}
+	sc.Set_rpcurl(server.URL)
+	sc.Set_from(testProducer)
+	sc.Set_skip_eventlistener()
+	sc.Set_contract_address(testMetering)
+	return MeteringFactory(sc), server
+}
+
+func TestMeterHash(t *testing.T) {
+	meter := make([]byte, 96)
+	meter[31], meter[62], meter[63] = 5, 0x01, 0x02
+	copy(meter[64:], testID)
+	expected := sha3.Sum256(meter)
+	if hash := Meter_hash(5, 0x102, testID); !bytes.Equal(hash, expected[:]) {
+		t.Errorf("Meter_hash returned %x, expected %x\n", hash, expected)
+	}
+	r := &Reading{Count: 5, Time: 0x102, AgreementID: testID}
+	if hash := r.Meter_hash(); !bytes.Equal(hash, expected[:]) {
+		t.Errorf("Reading.Meter_hash returned %x, expected %x\n", hash, expected)
+	}
+}
+
+func TestMetering(t *testing.T) {
+	node := &contracttest.Node{Results: testResults, Outputs: map[string]string{
+		"create_meter(uint256,uint256,bytes32,bytes32,bytes,bytes32,bytes,bytes,address)": "0x" + contracttest.Word("3"),
+		"read_meter(bytes32,address)":                 "0x" + contracttest.Word("7") + contracttest.Word("5f5e100"),
+		"admin_delete_meter(address,address,bytes32)": "0x" + contracttest.Word("1"),
+	}}
+	m, server := testMeteringClient(t, node)
+	defer server.Close()
+	ctx := context.Background()
+
+	// The signatures are gathered from the parties that owe them.
+	producer, consumer := &fakeSigner{}, &fakeSigner{}
+	r := &Reading{Count: 10, Time: 100000000, AgreementID: testID, Consumer: testConsumer, ContractHash: testHash}
+	if err := m.Create(ctx, r); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Create of an unsigned reading returned %v, expected %v\n", err, ErrBadSignature)
+	}
+	if err := m.Sign_reading(ctx, r, producer, consumer); err != nil {
+		t.Fatalf("Sign_reading returned error: %v\n", err)
+	}
+	expected := []string{testConsumer + " 0x" + hex.EncodeToString(r.Meter_hash()), testConsumer + " 0x" + hex.EncodeToString(testHash)}
+	if fmt.Sprint(consumer.signed) != fmt.Sprint(expected) {
+		t.Errorf("The consumer signed %v, expected %v\n", consumer.signed, expected)
+	}
+	if expected := []string{testProducer + " 0x" + hex.EncodeToString(testHash)}; fmt.Sprint(producer.signed) != fmt.Sprint(expected) {
+		t.Errorf("The producer signed %v, expected %v\n", producer.signed, expected)
+	}
+	if len(r.ConsumerMeterSig) != 65 || len(r.ProducerSig) != 65 || len(r.ConsumerSig) != 65 {
+		t.Errorf("Sign_reading left signatures of %v, %v and %v bytes\n", len(r.ConsumerMeterSig), len(r.ProducerSig), len(r.ConsumerSig))
+	}
+	if err := m.Sign_reading(ctx, r, nil, nil); err != nil {
+		t.Errorf("Sign_reading of a signed reading returned error: %v\n", err)
+	}
+
+	// A signature that doesn't match is refused locally.
+	good := r.ConsumerSig
+	r.ConsumerSig = r.ProducerSig
+	if err := m.Create(ctx, r); !errors.Is(err, ErrBadSignature) || !strings.Contains(err.Error(), testProducer) {
+		t.Errorf("Create returned %v, expected %v by %v\n", err, ErrBadSignature, testProducer)
+	}
+	r.ConsumerSig = good
+
+	// Refusals are found by eth_call and never sent as transactions.
+	if err := m.Create(ctx, r); !errors.Is(err, ErrStaleReading) {
+		t.Errorf("Create returned %v, expected %v\n", err, ErrStaleReading)
+	}
+	if err := m.Admin_delete(ctx, testProducer, testConsumer, testID); !errors.Is(err, ErrNoMeter) {
+		t.Errorf("Admin_delete returned %v, expected %v\n", err, ErrNoMeter)
+	}
+	if n := node.Calls("eth_sendTransaction"); n != 0 {
+		t.Errorf("%v refused operations were sent as transactions\n", n)
+	}
+
+	if count, time, err := m.Read(ctx, testConsumer, testID); err != nil || count != 7 || time != 100000000 {
+		t.Errorf("Read returned %v %v %v, expected 7 100000000\n", count, time, err)
+	}
+}
+
+func TestMeterEvents(t *testing.T) {
+	topics := func(code string) []string {
+		return []string{"0x" + contracttest.Word(code), "0x" + contracttest.Word(testProducer[2:]), "0x" + contracttest.Word(testConsumer[2:]), "0x" + hex.EncodeToString(testID)}
+	}
+	sig := func(b byte) string {
+		return contracttest.Word("41") + hex.EncodeToString(bytes.Repeat([]byte{b}, 65)) + strings.Repeat("0", 62)
+	}
+	meterHash := Meter_hash(10, 100, testID)
+	data := "0x" + contracttest.Word("a") + contracttest.Word("64") + hex.EncodeToString(meterHash) + contracttest.Word("e0") + hex.EncodeToString(testHash) + contracttest.Word("160") + contracttest.Word("1e0") + sig(1) + sig(2) + sig(3)
+	node := &contracttest.Node{Results: testResults, Logs: []interface{}{
+		map[string]interface{}{"blockNumber": "0x5", "logIndex": "0x0", "data": "0x", "topics": topics("0")},
+		map[string]interface{}{"blockNumber": "0x5", "logIndex": "0x1", "data": data, "topics": topics("1")},
+		map[string]interface{}{"blockNumber": "0x6", "logIndex": "0x0", "data": "0x", "topics": topics("3")},
+	}}
+	m, server := testMeteringClient(t, node)
+	defer server.Close()
+
+	events := make([]*MeterEvent, 0, 3)
+	err := m.Get_contract().Query_logs(context.Background(), &contract_api.LogQuery{Filter: meter_filter, FromBlock: 1, ToBlock: 0x10}, func(ev *contract_api.Event) {
+		if mev, err := m.Decode_event(ev); err != nil {
+			t.Errorf("Decode_event returned error: %v\n", err)
+		} else {
+			events = append(events, mev)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Query_logs returned error: %v\n", err)
+	} else if len(events) != 3 {
+		t.Fatalf("Decoded %v events, expected 3\n", len(events))
+	}
+	for ix, code := range []EventCode{Created, Created_detail, Admin_deleted} {
+		if ev := events[ix]; ev.Code != code || ev.Producer != testProducer || ev.Consumer != testConsumer || !bytes.Equal(ev.AgreementID, testID) {
+			t.Errorf("Event %v decoded as %v between %v and %v, expected %v\n", ix, ev.Code, ev.Producer, ev.Consumer, code)
+		}
+	}
+
+	r := events[1].Reading
+	if r == nil {
+		t.Fatalf("%v decoded without a reading\n", events[1].Code)
+	}
+	if r.Count != 10 || r.Time != 100 || !bytes.Equal(events[1].MeterHash, meterHash) || !bytes.Equal(r.ContractHash, testHash) {
+		t.Errorf("%v decoded as count %v, time %v, meter hash %x and contract hash %x\n", events[1].Code, r.Count, r.Time, events[1].MeterHash, r.ContractHash)
+	}
+	for ix, s := range [][]byte{r.ConsumerMeterSig, r.ProducerSig, r.ConsumerSig} {
+		if !bytes.Equal(s, bytes.Repeat([]byte{byte(ix + 1)}, 65)) {
+			t.Errorf("Signature %v decoded as %x\n", ix, s)
+		}
+	}
+}
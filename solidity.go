@@ -2,6 +2,9 @@ package solidity
 
 // listed packages in this repo that should be available via 'go get X'
 import (
+	_ "github.com/open-horizon/go-solidity/agreements"
 	_ "github.com/open-horizon/go-solidity/contract_api"
+	_ "github.com/open-horizon/go-solidity/directory"
+	_ "github.com/open-horizon/go-solidity/metering"
 	_ "github.com/open-horizon/go-solidity/utility"
 )